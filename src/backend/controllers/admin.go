@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+)
+
+// AdminDiff compares two element files from the data directory, given by
+// name in ?from= and ?to=. They default to the scraped dataset and the
+// edited copy.
+func AdminDiff(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	fromPath, ok := dataFilePath(r.URL.Query().Get("from"), models.DataPath())
+	if !ok {
+		http.Error(w, "from must be a file name inside the data directory", http.StatusBadRequest)
+		return
+	}
+	toPath, ok := dataFilePath(r.URL.Query().Get("to"), models.CustomDataPath())
+	if !ok {
+		http.Error(w, "to must be a file name inside the data directory", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := models.DiffDatasetFiles(fromPath, toPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// dataFilePath resolves name against the data directory, refusing anything
+// that would leave it.
+func dataFilePath(name string, fallback string) (string, bool) {
+	if name == "" {
+		return fallback, true
+	}
+	if !filepath.IsLocal(name) {
+		return "", false
+	}
+	return filepath.Join(filepath.Dir(models.DataPath()), name), true
+}
+
+// AdminScrapeStatus reports the scheduled re-scrape, its settings and the
+// outcome of the last run.
+func AdminScrapeStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.GetScrapeStatus()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
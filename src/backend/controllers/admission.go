@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+var (
+	admissionMu sync.Mutex
+	running     int
+	runningByIP = map[string]int{}
+)
+
+// admitSearch reserves a slot for a search from ip under the concurrency
+// limits. The returned release must be called once the search is done.
+func admitSearch(ip string) (func(), *models.LimitExceededError) {
+	limits := models.GetSearchLimits()
+
+	admissionMu.Lock()
+	defer admissionMu.Unlock()
+	if limits.MaxConcurrent > 0 && running >= limits.MaxConcurrent {
+		return nil, &models.LimitExceededError{Limit: models.LimitMaxConcurrent, Max: int64(limits.MaxConcurrent)}
+	}
+	if limits.MaxConcurrentPerIP > 0 && runningByIP[ip] >= limits.MaxConcurrentPerIP {
+		return nil, &models.LimitExceededError{Limit: models.LimitMaxConcurrentPerIP, Max: int64(limits.MaxConcurrentPerIP)}
+	}
+	running++
+	runningByIP[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			admissionMu.Lock()
+			defer admissionMu.Unlock()
+			running--
+			if runningByIP[ip]--; runningByIP[ip] <= 0 {
+				delete(runningByIP, ip)
+			}
+		})
+	}, nil
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is only
+// read from trusted proxies, since anyone could set it to get around the
+// per-IP limit, and the client is the last address in it that is not one of
+// them.
+func clientIP(r *http.Request) string {
+	host := remoteHost(r)
+	if !isTrustedProxy(host) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(ip); err != nil {
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"testing"
+)
+
+func TestAdmitSearch(t *testing.T) {
+	type admit struct {
+		ip      string
+		limit   string // Limit the search runs into, "" when it is admitted
+		release int    // Before admitting, release the search admitted at this step, -1 for none
+	}
+
+	tests := []struct {
+		name   string
+		limits models.SearchLimits
+		steps  []admit
+	}{
+		{
+			name:   "global limit",
+			limits: models.SearchLimits{MaxConcurrent: 2},
+			steps: []admit{
+				{"a", "", -1},
+				{"b", "", -1},
+				{"c", models.LimitMaxConcurrent, -1},
+				{"c", "", 0},
+			},
+		},
+		{
+			name:   "per IP limit",
+			limits: models.SearchLimits{MaxConcurrentPerIP: 1},
+			steps: []admit{
+				{"a", "", -1},
+				{"a", models.LimitMaxConcurrentPerIP, -1},
+				{"b", "", -1},
+				{"a", "", 0},
+			},
+		},
+		{
+			name:   "global limit before per IP limit",
+			limits: models.SearchLimits{MaxConcurrent: 1, MaxConcurrentPerIP: 1},
+			steps: []admit{
+				{"a", "", -1},
+				{"a", models.LimitMaxConcurrent, -1},
+				{"b", models.LimitMaxConcurrent, -1},
+			},
+		},
+		{
+			name:   "release twice frees one slot",
+			limits: models.SearchLimits{MaxConcurrent: 2},
+			steps: []admit{
+				{"a", "", -1},
+				{"b", "", -1},
+				{"c", "", 0},
+				{"d", models.LimitMaxConcurrent, 0},
+			},
+		},
+	}
+
+	defer models.SetSearchLimits(models.GetSearchLimits())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models.SetSearchLimits(tt.limits)
+			releases := make([]func(), len(tt.steps))
+			// Releasing again does nothing
+			defer func() {
+				for _, release := range releases {
+					if release != nil {
+						release()
+					}
+				}
+			}()
+
+			for i, step := range tt.steps {
+				if step.release >= 0 {
+					releases[step.release]()
+				}
+				release, limitErr := admitSearch(step.ip)
+				switch {
+				case step.limit == "" && limitErr != nil:
+					t.Fatalf("step %d: search from %s not admitted: %v", i, step.ip, limitErr)
+				case step.limit != "" && limitErr == nil:
+					t.Fatalf("step %d: search from %s admitted, want %s", i, step.ip, step.limit)
+				case step.limit != "" && limitErr.Limit != step.limit:
+					t.Fatalf("step %d: limit = %s, want %s", i, limitErr.Limit, step.limit)
+				}
+				releases[i] = release
+			}
+		})
+	}
+
+	admissionMu.Lock()
+	defer admissionMu.Unlock()
+	if running != 0 || len(runningByIP) != 0 {
+		t.Errorf("after every release running = %d, by IP = %v, want none", running, runningByIP)
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// assetBaseURL is BASE_URL, or the scheme and host the request was sent to
+// so a deployment without BASE_URL still returns working asset URLs. Behind
+// a trusted proxy those come from X-Forwarded-Proto and X-Forwarded-Host.
+func assetBaseURL(r *http.Request) string {
+	if baseURL := models.BaseURL(); baseURL != "" {
+		return baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if fromTrustedProxy(r) {
+		if proto := forwardedHeader(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := forwardedHeader(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+	return scheme + "://" + host + "/"
+}
+
+// AssetsGet serves the file of an asset key from the public directory.
+func AssetsGet(w http.ResponseWriter, r *http.Request) {
+	filePath, err := models.AssetFile(r.PathValue("key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(filePath)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
@@ -3,7 +3,9 @@ package controllers
 import (
 	"ccp/backend/models"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 )
 
 func ElementsGetAll(w http.ResponseWriter, r *http.Request) {
@@ -12,7 +14,21 @@ func ElementsGetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	elements := models.GetElementsFromNameToNodeDTO()
+	// Optional ?base=Time,Life recomputes tiers and recipes for that start set
+	// and ?recipe_policy= picks which recipes are listed
+	var base []string
+	for _, name := range strings.Split(r.URL.Query().Get("base"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			base = append(base, name)
+		}
+	}
+	view, err := models.GetGraphView(base, r.URL.Query().Get("recipe_policy"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	elements := models.GetElementsFromNameToNodeDTO(view, assetBaseURL(r))
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(elements); err != nil {
@@ -20,3 +36,106 @@ func ElementsGetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type ElementRequest struct {
+	Name      string     `json:"name"`
+	ImagePath string     `json:"image_path"`
+	Recipes   [][]string `json:"recipes"`
+}
+
+type RecipeRequest struct {
+	ElementOneName string `json:"element_one"`
+	ElementTwoName string `json:"element_two"`
+}
+
+func ElementsCreate(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req ElementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	element, err := models.AddElement(req.Name, req.ImagePath, req.Recipes, assetBaseURL(r))
+	if err != nil {
+		writeGraphEditError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(element)
+}
+
+func ElementsDelete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if err := models.RemoveElement(r.PathValue("name")); err != nil {
+		writeGraphEditError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ElementRecipesCreate(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req RecipeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	element, err := models.AddRecipe(r.PathValue("name"), req.ElementOneName, req.ElementTwoName, assetBaseURL(r))
+	if err != nil {
+		writeGraphEditError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(element)
+}
+
+func ElementRecipesDelete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// DELETE requests carry no body, the recipe is in the query
+	query := r.URL.Query()
+	one, two := query.Get("element_one"), query.Get("element_two")
+	if one == "" || two == "" {
+		http.Error(w, "element_one and element_two query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	element, err := models.RemoveRecipe(r.PathValue("name"), one, two, assetBaseURL(r))
+	if err != nil {
+		writeGraphEditError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(element)
+}
+
+func writeGraphEditError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrElementNotFound), errors.Is(err, models.ErrRecipeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrElementExists), errors.Is(err, models.ErrRecipeExists), errors.Is(err, models.ErrElementInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidRecipe):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
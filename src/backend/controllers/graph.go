@@ -12,7 +12,7 @@ func GetElementsGraph(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	safeGraphNode := models.GetJSONDTONodes()
+	safeGraphNode := models.GetJSONDTONodes(assetBaseURL(r))
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(safeGraphNode); err != nil {
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// trustedProxies are the reverse proxies in front of the server, from
+// TRUSTED_PROXIES as a comma separated list of addresses and CIDR ranges.
+// Only requests from them may set the X-Forwarded-* headers.
+var trustedProxies = sync.OnceValue(func() []netip.Prefix {
+	return parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+})
+
+func parseTrustedProxies(value string) []netip.Prefix {
+	prefixes := []netip.Prefix{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		} else {
+			slog.Warn("Ignoring invalid trusted proxy", "proxy", entry)
+		}
+	}
+	return prefixes
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies() {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// fromTrustedProxy reports whether r was sent by a trusted proxy, so its
+// X-Forwarded-* headers describe the client's request.
+func fromTrustedProxy(r *http.Request) bool {
+	return isTrustedProxy(remoteHost(r))
+}
+
+// forwardedHeader is the first value of a forwarding header, which proxies
+// may send as a comma separated list.
+func forwardedHeader(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"log/slog"
+	"time"
+)
+
+// Controls a client sends as {"replay_control": ...} during a replay
+const (
+	ReplayPause  = "pause"
+	ReplayResume = "resume"
+	ReplayStep   = "step"  // Show the next update and pause
+	ReplayBack   = "back"  // Show the previous update and pause
+	ReplaySeek   = "seek"  // Show update number position, keeps playing or paused
+	ReplaySpeed  = "speed" // Change speed
+	ReplayStop   = "stop"
+)
+
+// ReplayControl is a message that controls the replay of a connection.
+type ReplayControl struct {
+	Control  string  `json:"replay_control"`
+	Position int     `json:"position,omitempty"`
+	Speed    float64 `json:"speed,omitempty"`
+}
+
+// ReplayState is sent with every replayed update and after every control.
+// Position is how many updates were shown so far.
+type ReplayState struct {
+	SearchID string  `json:"search_id"`
+	Position int     `json:"position"`
+	Total    int     `json:"total"`
+	Paused   bool    `json:"paused"`
+	Speed    float64 `json:"speed"`
+	Done     bool    `json:"done,omitempty"`
+}
+
+type ReplayFrame struct {
+	TreeUpdate
+	Replay ReplayState `json:"replay"`
+}
+
+// replaySession re-streams the recorded updates of a saved search.
+type replaySession struct {
+	client   *wsClient
+	search   *models.SavedSearch
+	events   []models.SearchEvent
+	baseURL  string
+	delayMs  int // Fixed time between updates, or the recorded times divided by speed
+	stream   *treeStream
+	state    ReplayState
+	controls chan ReplayControl
+	done     chan struct{}
+}
+
+// startReplay starts replaying the saved search asked for by req on client.
+func startReplay(client *wsClient, req RecipeTreeRequest, baseURL string, protocol string) (*replaySession, error) {
+	search, err := models.GetSavedSearch(req.ReplaySearch)
+	if err != nil {
+		return nil, err
+	}
+	events, err := models.LoadSearchEvents(req.ReplaySearch)
+	if err != nil {
+		return nil, err
+	}
+	speed := req.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	session := &replaySession{
+		client:   client,
+		search:   search,
+		events:   events,
+		baseURL:  baseURL,
+		delayMs:  req.DelayMs,
+		stream:   newTreeStream(protocol, searchBaseElements(search.Base, search.RecipePolicy), req.SnapshotEvery),
+		state:    ReplayState{SearchID: search.ID, Total: len(events), Speed: speed},
+		controls: make(chan ReplayControl),
+		done:     make(chan struct{}),
+	}
+	go session.run()
+	return session, nil
+}
+
+// control hands c to the session, false once the session has ended.
+func (s *replaySession) control(c ReplayControl) bool {
+	select {
+	case s.controls <- c:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *replaySession) stop() {
+	s.control(ReplayControl{Control: ReplayStop})
+}
+
+func (s *replaySession) run() {
+	defer close(s.done)
+
+	// A search without recorded updates goes straight to its result
+	if len(s.events) == 0 {
+		if err := s.finish(); err != nil {
+			slog.Warn("Replay write error", "search_id", s.search.ID, "error", err)
+			return
+		}
+	}
+
+	var timer *time.Timer
+	var tick <-chan time.Time
+	for {
+		if timer != nil {
+			timer.Stop()
+			timer, tick = nil, nil
+		}
+		if !s.state.Paused && s.state.Position < len(s.events) {
+			timer = time.NewTimer(s.wait())
+			tick = timer.C
+		}
+
+		var err error
+		select {
+		case <-tick:
+			err = s.show(s.state.Position + 1)
+		case c := <-s.controls:
+			switch c.Control {
+			case ReplayStop:
+				return
+			case ReplayPause:
+				s.state.Paused = true
+				err = s.writeState()
+			case ReplayResume:
+				s.state.Paused = false
+				// Resuming at the end starts over
+				if s.state.Position >= len(s.events) {
+					s.state.Position = 0
+					s.state.Done = false
+				}
+				err = s.writeState()
+			case ReplayStep:
+				s.state.Paused = true
+				if s.state.Position < len(s.events) {
+					err = s.show(s.state.Position + 1)
+				} else {
+					err = s.writeState()
+				}
+			case ReplayBack:
+				s.state.Paused = true
+				if s.state.Position > 1 {
+					s.state.Done = false
+					err = s.show(s.state.Position - 1)
+				} else {
+					err = s.writeState()
+				}
+			case ReplaySeek:
+				s.state.Done = false
+				err = s.show(min(max(c.Position, 1), len(s.events)))
+			case ReplaySpeed:
+				if c.Speed > 0 {
+					s.state.Speed = c.Speed
+				}
+				err = s.writeState()
+			default:
+				err = s.write(map[string]string{"error": "unknown replay_control: " + c.Control})
+			}
+		case <-searchCtx.Done():
+			return
+		}
+		if err != nil {
+			slog.Warn("Replay write error", "search_id", s.search.ID, "error", err)
+			return
+		}
+	}
+}
+
+// wait is the time before the next update, from the recorded times unless
+// the replay has a fixed delay.
+func (s *replaySession) wait() time.Duration {
+	if s.delayMs > 0 {
+		return time.Duration(float64(s.delayMs)/s.state.Speed) * time.Millisecond
+	}
+	previous := int64(0)
+	if s.state.Position > 0 {
+		previous = s.events[s.state.Position-1].AtMs
+	}
+	gap := s.events[s.state.Position].AtMs - previous
+	return time.Duration(float64(gap)/s.state.Speed) * time.Millisecond
+}
+
+// show sends update number position, and the saved result once the replay
+// reaches the end.
+func (s *replaySession) show(position int) error {
+	s.state.Position = position
+	if position == 0 {
+		return s.finish()
+	}
+	event := s.events[position-1]
+	err := s.write(ReplayFrame{
+		TreeUpdate: s.stream.frame(TreeUpdate{
+			ExploringTree: models.ResolveTreeAssets(event.Tree, s.baseURL),
+			DurationMs:    event.DurationMs,
+			NodesExplored: event.NodesExplored,
+		}),
+		Replay: s.state,
+	})
+	if err != nil || position < len(s.events) {
+		return err
+	}
+	return s.finish()
+}
+
+func (s *replaySession) finish() error {
+	s.state.Paused = true
+	s.state.Done = true
+	trees := make([]*models.RecipeTreeNode, len(s.search.Trees))
+	for i, tree := range s.search.Trees {
+		trees[i] = models.ResolveTreeAssets(tree, s.baseURL)
+	}
+	state := s.state
+	return s.write(FinalResponse{
+		Trees:         trees,
+		DurationMs:    s.search.DurationMs,
+		NodesExplored: s.search.NodesExplored,
+		SearchID:      s.search.ID,
+		Replay:        &state,
+	})
+}
+
+func (s *replaySession) writeState() error {
+	return s.write(map[string]ReplayState{"replay": s.state})
+}
+
+func (s *replaySession) write(frame any) error {
+	s.client.writeMu.Lock()
+	defer s.client.writeMu.Unlock()
+	return s.client.conn.WriteJSON(frame)
+}
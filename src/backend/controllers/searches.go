@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type SearchesListResponse struct {
+	Searches []models.SearchSummary `json:"searches"`
+	Total    int                    `json:"total"`
+	Offset   int                    `json:"offset"`
+	Limit    int                    `json:"limit"`
+}
+
+// SearchesList lists saved searches newest first, optionally only those for
+// ?target=, paged with ?offset= and ?limit= (50 by default, at most 200).
+func SearchesList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, ok := queryInt(query.Get("offset"), 0)
+	if !ok {
+		http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	limit, ok := queryInt(query.Get("limit"), 50)
+	if !ok || limit == 0 {
+		http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	limit = min(limit, 200)
+
+	searches, total := models.ListSavedSearches(query.Get("target"), offset, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(SearchesListResponse{
+		Searches: searches,
+		Total:    total,
+		Offset:   offset,
+		Limit:    limit,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SearchesGet returns a saved search with its trees as they were found,
+// without running the search again.
+func SearchesGet(w http.ResponseWriter, r *http.Request) {
+	search, err := models.GetSavedSearch(r.PathValue("id"))
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+
+	baseURL := assetBaseURL(r)
+	for i, tree := range search.Trees {
+		search.Trees[i] = models.ResolveTreeAssets(tree, baseURL)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(search); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type SearchEventsResponse struct {
+	SearchID  string               `json:"search_id"`
+	Events    []models.SearchEvent `json:"events"`
+	Total     int                  `json:"total"`
+	Truncated bool                 `json:"truncated"`
+}
+
+// SearchEventsGet returns the recorded exploration updates of a saved
+// search from ?offset=, at most ?limit= of them, for clients that replay it
+// themselves. The WebSocket replays it with {"replay_search": id}.
+func SearchEventsGet(w http.ResponseWriter, r *http.Request) {
+	offset, ok := queryInt(r.URL.Query().Get("offset"), 0)
+	if !ok {
+		http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	limit, ok := queryInt(r.URL.Query().Get("limit"), 0)
+	if !ok {
+		http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	search, err := models.GetSavedSearch(r.PathValue("id"))
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	events, err := models.LoadSearchEvents(search.ID)
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+
+	response := SearchEventsResponse{
+		SearchID:  search.ID,
+		Total:     len(events),
+		Truncated: search.EventsTruncated,
+	}
+	events = events[min(offset, len(events)):]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	baseURL := assetBaseURL(r)
+	for i := range events {
+		events[i].Tree = models.ResolveTreeAssets(events[i].Tree, baseURL)
+	}
+	response.Events = events
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func writeSearchError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrSearchNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// queryInt parses a non-negative query parameter, fallback when it is empty.
+func queryInt(value string, fallback int) (int, bool) {
+	if value == "" {
+		return fallback, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, false
+	}
+	return parsed, true
+}
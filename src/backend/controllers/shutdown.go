@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ShutdownMessage is sent to every WebSocket client when the server starts
+// shutting down, and as the error of searches asked for afterwards.
+const ShutdownMessage = "server shutting down"
+
+// wsClient is an open WebSocket connection. writeMu serializes writes to
+// conn between the search, its update writer and shutdown.
+type wsClient struct {
+	conn    *websocket.Conn
+	writeMu *sync.Mutex
+}
+
+var (
+	clientsMu    sync.Mutex
+	clients      = map[*wsClient]bool{}
+	shuttingDown bool
+	// Closed once shutdown begins
+	shutdownStarted = make(chan struct{})
+	// Running searches, only added to while not shutting down
+	searchesWg sync.WaitGroup
+
+	// searchCtx is the parent of every search, canceled once the grace
+	// period of a shutdown is over
+	searchCtx, cancelSearches = context.WithCancel(context.Background())
+)
+
+func addClient(client *wsClient) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients[client] = true
+}
+
+func removeClient(client *wsClient) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	delete(clients, client)
+}
+
+// startSearch registers a search so shutdown waits for it. It reports false
+// once the server is shutting down, and the search must not run.
+func startSearch() bool {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if shuttingDown {
+		return false
+	}
+	searchesWg.Add(1)
+	return true
+}
+
+func finishSearch() {
+	searchesWg.Done()
+}
+
+// BeginShutdown stops new searches from starting and tells every WebSocket
+// client that the server is shutting down.
+func BeginShutdown() {
+	clientsMu.Lock()
+	if !shuttingDown {
+		shuttingDown = true
+		close(shutdownStarted)
+	}
+	open := make([]*wsClient, 0, len(clients))
+	for client := range clients {
+		open = append(open, client)
+	}
+	clientsMu.Unlock()
+
+	for _, client := range open {
+		client.writeMu.Lock()
+		client.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		err := client.conn.WriteJSON(map[string]any{"error": ShutdownMessage, "shutting_down": true})
+		client.conn.SetWriteDeadline(time.Time{})
+		client.writeMu.Unlock()
+		if err != nil {
+			slog.Debug("Failed to send shutdown frame", "error", err)
+		}
+	}
+	slog.Info("Shutting down, no new searches are accepted", "clients", len(open))
+}
+
+// FinishShutdown waits for running searches until ctx is done, cancels the
+// ones still running and closes every WebSocket connection.
+func FinishShutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		searchesWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Grace period over, canceling running searches")
+		cancelSearches()
+		// Canceled searches stop at their next step
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			slog.Warn("Searches did not stop after being canceled")
+		}
+	}
+
+	clientsMu.Lock()
+	open := make([]*wsClient, 0, len(clients))
+	for client := range clients {
+		open = append(open, client)
+	}
+	clientsMu.Unlock()
+
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, ShutdownMessage)
+	for _, client := range open {
+		client.writeMu.Lock()
+		client.conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(time.Second))
+		client.writeMu.Unlock()
+		client.conn.Close()
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"encoding/json"
+	"net/http"
+)
+
+// SpritesGet returns the sprite atlas, the sprite of each element is listed
+// in its DTO.
+func SpritesGet(w http.ResponseWriter, r *http.Request) {
+	atlas := models.GetSpriteAtlas(assetBaseURL(r))
+	if atlas == nil {
+		http.Error(w, "Sprite atlas not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(atlas); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"ccp/backend/logging"
+	"ccp/backend/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecipesStream runs a search like /ws for clients that cannot use a
+// WebSocket and streams it as Server-Sent Events: "update" and "progress"
+// events while it runs, then one "final" or "error" event. The query takes
+// the fields of RecipeTreeRequest, with base as a comma separated list and
+// protocol=ccp.v2 for tree diffs.
+func RecipesStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	req, err := streamRequestFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	protocol := r.URL.Query().Get("protocol")
+	if protocol != ProtocolV2 {
+		protocol = ProtocolV1
+	}
+
+	if !startSearch() {
+		writeJSONError(w, http.StatusServiceUnavailable, ErrorResponse{Error: ShutdownMessage, ShuttingDown: true})
+		return
+	}
+	defer finishSearch()
+	release, limitErr := admitSearch(clientIP(r))
+	if limitErr != nil {
+		metrics.SearchesLimited.WithLabelValues(limitErr.Limit).Inc()
+		writeJSONError(w, http.StatusTooManyRequests, limitExceededResponse(limitErr, nil, 0, 0))
+		return
+	}
+
+	// Like a WebSocket, the stream stays open past the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// The search stops when the client goes away
+	ctx, cancel := context.WithCancel(searchCtx)
+	defer cancel()
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+
+	var writeMu sync.Mutex
+	write := func(frame any) error {
+		data, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", streamEventName(frame), data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	searchID := logging.RequestID(r.Context())
+	if searchID == "" {
+		searchID = logging.NewRequestID()
+	}
+	final := runSearch(ctx, req, searchID, assetBaseURL(r), protocol, release, write)
+	write(final)
+}
+
+// streamEventName is the SSE event a frame is sent as.
+func streamEventName(frame any) string {
+	switch frame.(type) {
+	case TreeUpdate:
+		return "update"
+	case ProgressFrame:
+		return "progress"
+	case FinalResponse:
+		return "final"
+	default:
+		return "error"
+	}
+}
+
+// streamRequestFromQuery reads a RecipeTreeRequest from the query of
+// /api/recipes/stream. max_tree_count defaults to 1.
+func streamRequestFromQuery(query url.Values) (RecipeTreeRequest, error) {
+	req := RecipeTreeRequest{
+		Target:       query.Get("target"),
+		Mode:         query.Get("mode"),
+		MaxTreeCount: 1,
+		RecipePolicy: query.Get("recipe_policy"),
+		Stream:       query.Get("stream"),
+	}
+	if req.Target == "" {
+		return req, fmt.Errorf("target is required")
+	}
+	for _, name := range strings.Split(query.Get("base"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Base = append(req.Base, name)
+		}
+	}
+
+	for name, field := range map[string]*int{
+		"max_tree_count": &req.MaxTreeCount,
+		"delay_ms":       &req.DelayMs,
+		"snapshot_every": &req.SnapshotEvery,
+		"progress_ms":    &req.ProgressMs,
+	} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return req, fmt.Errorf("%s must be an integer", name)
+			}
+			*field = parsed
+		}
+	}
+	if value := query.Get("no_cache"); value != "" {
+		noCache, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("no_cache must be a boolean")
+		}
+		req.NoCache = noCache
+	}
+	if value := query.Get("replay"); value != "" {
+		replay, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("replay must be a boolean")
+		}
+		req.Replay = &replay
+	}
+	return req, nil
+}
+
+func writeJSONError(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"ccp/backend/metrics"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stream modes a request picks with "stream"
+const (
+	StreamAll    = "all"    // Every update in order, kept on disk while the client is behind
+	StreamLatest = "latest" // Only the newest update at each tick
+)
+
+// Time between progress frames unless the request sets progress_ms
+const defaultProgressInterval = time.Second
+
+type SearchProgressInfo struct {
+	NodesExplored  int32 `json:"nodes_explored"`
+	Frontier       int64 `json:"frontier"`
+	TreesFound     int64 `json:"trees_found"`
+	ElapsedMs      int64 `json:"elapsed_ms"`
+	PendingUpdates int   `json:"pending_updates"` // Updates found but not sent yet
+}
+
+type ProgressFrame struct {
+	Progress SearchProgressInfo `json:"progress"`
+}
+
+// streamBufferUpdates is how many updates a StreamAll stream keeps in memory
+// before it writes them to disk, from STREAM_BUFFER_UPDATES.
+func streamBufferUpdates() int {
+	if value := os.Getenv("STREAM_BUFFER_UPDATES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 1000
+}
+
+// updateStreamer sends the updates of one search at the client's pace while
+// the search runs at full speed, with a progress frame now and then.
+type updateStreamer struct {
+	mode             string
+	interval         time.Duration // Time between updates, none when zero
+	progressInterval time.Duration // Time between progress frames, none when zero
+	trees            *treeStream
+	progress         func() SearchProgressInfo
+	write            func(frame any) error
+
+	mu      sync.Mutex
+	memory  []TreeUpdate
+	spilled *spillQueue // Updates after the memory buffer filled up
+	latest  *TreeUpdate
+	closed  bool
+	failed  bool
+	wake    chan struct{}
+	unpaced chan struct{} // Closed to send the rest without waiting for the pace
+	stop    chan struct{} // Closed to stop sending
+	done    chan struct{}
+}
+
+func newUpdateStreamer(
+	mode string,
+	interval time.Duration,
+	progressInterval time.Duration,
+	trees *treeStream,
+	progress func() SearchProgressInfo,
+	write func(frame any) error,
+) *updateStreamer {
+	if mode != StreamLatest {
+		mode = StreamAll
+	}
+	s := &updateStreamer{
+		mode:             mode,
+		interval:         interval,
+		progressInterval: progressInterval,
+		trees:            trees,
+		progress:         progress,
+		write:            write,
+		wake:             make(chan struct{}, 1),
+		unpaced:          make(chan struct{}),
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// push queues update, it never blocks the search for the client.
+func (s *updateStreamer) push(update TreeUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.failed {
+		return
+	}
+
+	if s.mode == StreamLatest {
+		if s.latest != nil {
+			metrics.UpdatesDropped.Inc()
+		}
+		s.latest = &update
+	} else if s.spilled == nil && len(s.memory) < streamBufferUpdates() {
+		s.memory = append(s.memory, update)
+	} else {
+		if s.spilled == nil {
+			spilled, err := newSpillQueue()
+			if err != nil {
+				// Without a file the updates stay in memory
+				slog.Warn("Failed to buffer updates on disk", "error", err)
+				s.memory = append(s.memory, update)
+				s.signal()
+				return
+			}
+			s.spilled = spilled
+		}
+		if err := s.spilled.push(update); err != nil {
+			slog.Warn("Failed to buffer update on disk", "error", err)
+			metrics.UpdatesDropped.Inc()
+		} else {
+			metrics.UpdatesSpilled.Inc()
+		}
+	}
+	s.signal()
+}
+
+func (s *updateStreamer) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next update to send. It reports false when there is none,
+// and whether the stream is closed.
+func (s *updateStreamer) pop() (TreeUpdate, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latest != nil {
+		update := *s.latest
+		s.latest = nil
+		return update, true, s.closed
+	}
+	if len(s.memory) > 0 {
+		update := s.memory[0]
+		s.memory[0] = TreeUpdate{}
+		s.memory = s.memory[1:]
+		return update, true, s.closed
+	}
+	if s.spilled != nil {
+		update, err := s.spilled.pop()
+		if err != nil || s.spilled.len() == 0 {
+			if err != nil {
+				slog.Warn("Failed to read buffered updates", "dropped", s.spilled.len(), "error", err)
+			}
+			s.spilled.close()
+			s.spilled = nil
+		}
+		if err == nil {
+			return update, true, s.closed
+		}
+	}
+	return TreeUpdate{}, false, s.closed
+}
+
+func (s *updateStreamer) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingLocked()
+}
+
+func (s *updateStreamer) pendingLocked() int {
+	pending := len(s.memory)
+	if s.latest != nil {
+		pending++
+	}
+	if s.spilled != nil {
+		pending += s.spilled.len()
+	}
+	return pending
+}
+
+func (s *updateStreamer) run() {
+	defer close(s.done)
+
+	var pace, progressTick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+	if s.progressInterval > 0 {
+		ticker := time.NewTicker(s.progressInterval)
+		defer ticker.Stop()
+		progressTick = ticker.C
+	}
+
+	for {
+		update, ok, closed := s.pop()
+		if !ok {
+			if closed {
+				return
+			}
+			select {
+			case <-s.wake:
+			case <-progressTick:
+				if !s.sendProgress() {
+					return
+				}
+			}
+			continue
+		}
+
+		if pace != nil {
+		wait:
+			for {
+				select {
+				case <-pace:
+					break wait
+				case <-s.unpaced:
+					break wait
+				case <-s.stop:
+					return
+				case <-progressTick:
+					if !s.sendProgress() {
+						return
+					}
+				}
+			}
+		}
+		if err := s.write(s.trees.frame(update)); err != nil {
+			s.fail(err)
+			return
+		}
+		metrics.UpdatesSent.Inc()
+	}
+}
+
+func (s *updateStreamer) sendProgress() bool {
+	info := s.progress()
+	info.PendingUpdates = s.pending()
+	if err := s.write(ProgressFrame{Progress: info}); err != nil {
+		s.fail(err)
+		return false
+	}
+	return true
+}
+
+// fail stops queueing once the client cannot be written to.
+func (s *updateStreamer) fail(err error) {
+	slog.Warn("Update write error", "error", err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.memory = nil
+	s.latest = nil
+	if s.spilled != nil {
+		s.spilled.close()
+		s.spilled = nil
+	}
+}
+
+// finish waits until every queued update is sent, after the search is done.
+// Once the server starts shutting down the rest is sent without waiting for
+// the pace, and once ctx is done the rest is dropped.
+func (s *updateStreamer) finish(ctx context.Context) {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.signal()
+
+	select {
+	case <-s.done:
+		return
+	case <-shutdownStarted:
+		close(s.unpaced)
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	metrics.UpdatesDropped.Add(float64(s.pendingLocked()))
+	s.memory = nil
+	s.latest = nil
+	if s.spilled != nil {
+		s.spilled.close()
+		s.spilled = nil
+	}
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+}
+
+// spillQueue is a queue of updates in a temporary file.
+type spillQueue struct {
+	writer  *os.File
+	reader  *os.File
+	encoder *json.Encoder
+	decoder *json.Decoder
+	written int
+	read    int
+}
+
+func newSpillQueue() (*spillQueue, error) {
+	writer, err := os.CreateTemp("", "ccp-updates-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	reader, err := os.Open(writer.Name())
+	// The file is only used through the open handles
+	os.Remove(writer.Name())
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return &spillQueue{
+		writer:  writer,
+		reader:  reader,
+		encoder: json.NewEncoder(writer),
+		decoder: json.NewDecoder(reader),
+	}, nil
+}
+
+func (q *spillQueue) push(update TreeUpdate) error {
+	if err := q.encoder.Encode(update); err != nil {
+		return err
+	}
+	q.written++
+	return nil
+}
+
+func (q *spillQueue) pop() (TreeUpdate, error) {
+	var update TreeUpdate
+	err := q.decoder.Decode(&update)
+	q.read++
+	return update, err
+}
+
+func (q *spillQueue) len() int {
+	return q.written - q.read
+}
+
+func (q *spillQueue) close() {
+	q.writer.Close()
+	q.reader.Close()
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"ccp/backend/models"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowClient collects the updates written to it. The first write blocks
+// until the client is let go, so the updates pushed meanwhile are queued.
+type slowClient struct {
+	mu      sync.Mutex
+	sent    []int32 // Nodes explored of every update written
+	writing chan struct{}
+	gate    chan struct{}
+}
+
+func newSlowClient() *slowClient {
+	return &slowClient{writing: make(chan struct{}, 1), gate: make(chan struct{})}
+}
+
+func (c *slowClient) write(frame any) error {
+	c.mu.Lock()
+	if update, ok := frame.(TreeUpdate); ok {
+		c.sent = append(c.sent, update.NodesExplored)
+	}
+	c.mu.Unlock()
+	select {
+	case c.writing <- struct{}{}:
+	default:
+	}
+	<-c.gate
+	return nil
+}
+
+func testUpdate(nodes int32) TreeUpdate {
+	return TreeUpdate{ExploringTree: &models.RecipeTreeNode{Name: "Steam"}, NodesExplored: nodes}
+}
+
+func TestUpdateStreamer(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		buffer  int // Updates kept in memory before spilling
+		spilled bool
+		sent    []int32
+	}{
+		{name: "all in memory", mode: StreamAll, buffer: 1000, sent: []int32{1, 2, 3, 4, 5}},
+		{name: "all partly spilled", mode: StreamAll, buffer: 2, spilled: true, sent: []int32{1, 2, 3, 4, 5}},
+		{name: "latest", mode: StreamLatest, buffer: 1000, sent: []int32{1, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STREAM_BUFFER_UPDATES", strconv.Itoa(tt.buffer))
+			client := newSlowClient()
+			streamer := newUpdateStreamer(tt.mode, 0, 0, newTreeStream(ProtocolV1, nil, 0), nil, client.write)
+
+			// The first update is being written when the others come in
+			streamer.push(testUpdate(1))
+			<-client.writing
+			for nodes := int32(2); nodes <= 5; nodes++ {
+				streamer.push(testUpdate(nodes))
+			}
+			streamer.mu.Lock()
+			spilled := streamer.spilled != nil
+			streamer.mu.Unlock()
+			if spilled != tt.spilled {
+				t.Errorf("spilled = %v, want %v", spilled, tt.spilled)
+			}
+
+			close(client.gate)
+			streamer.finish(context.Background())
+			if !slices.Equal(client.sent, tt.sent) {
+				t.Errorf("sent = %v, want %v", client.sent, tt.sent)
+			}
+		})
+	}
+}
+
+// A search that is done must not wait for its updates to be paced out once
+// the client is gone or the server is shutting down.
+func TestUpdateStreamerFinish(t *testing.T) {
+	tests := []struct {
+		name     string
+		canceled bool
+		shutdown bool
+		sent     []int32
+	}{
+		{name: "canceled", canceled: true, sent: []int32{}},
+		{name: "shutting down", shutdown: true, sent: []int32{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(started chan struct{}) { shutdownStarted = started }(shutdownStarted)
+			shutdownStarted = make(chan struct{})
+			if tt.shutdown {
+				close(shutdownStarted)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.canceled {
+				cancel()
+			}
+			defer cancel()
+
+			client := newSlowClient()
+			close(client.gate)
+			streamer := newUpdateStreamer(StreamAll, time.Hour, 0, newTreeStream(ProtocolV1, nil, 0), nil, client.write)
+			for nodes := int32(1); nodes <= 3; nodes++ {
+				streamer.push(testUpdate(nodes))
+			}
+
+			finished := make(chan struct{})
+			go func() {
+				streamer.finish(ctx)
+				close(finished)
+			}()
+			select {
+			case <-finished:
+			case <-time.After(5 * time.Second):
+				t.Fatal("finish waited for the pace")
+			}
+			if sent := append([]int32{}, client.sent...); !slices.Equal(sent, tt.sent) {
+				t.Errorf("sent = %v, want %v", sent, tt.sent)
+			}
+			if pending := streamer.pending(); pending != 0 {
+				t.Errorf("pending after finish = %d, want 0", pending)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import "ccp/backend/models"
+
+// WebSocket subprotocols, picked by the client when it connects. Clients
+// that ask for none get ProtocolV1.
+const (
+	ProtocolV1 = "ccp.v1" // Every update carries the whole exploring tree
+	ProtocolV2 = "ccp.v2" // Updates carry TreeEvents, with a snapshot every few frames
+)
+
+// Updates between two snapshots in ProtocolV2, unless the request sets
+// snapshot_every
+const defaultSnapshotEvery = 20
+
+// treeStream turns the exploring trees of one search or replay into the
+// update frames of the connection's protocol.
+type treeStream struct {
+	diffs         bool
+	differ        *models.TreeDiffer
+	snapshotEvery int
+	seq           int
+}
+
+func newTreeStream(protocol string, baseElements []string, snapshotEvery int) *treeStream {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+	return &treeStream{
+		diffs:         protocol == ProtocolV2,
+		differ:        models.NewTreeDiffer(baseElements),
+		snapshotEvery: snapshotEvery,
+	}
+}
+
+// frame returns update as the next frame. In ProtocolV2 the tree is replaced
+// by the events since the previous frame, except in snapshots.
+func (s *treeStream) frame(update TreeUpdate) TreeUpdate {
+	if !s.diffs {
+		return update
+	}
+	s.seq++
+	update.Seq = s.seq
+	if (s.seq-1)%s.snapshotEvery == 0 {
+		s.differ.Reset(update.ExploringTree)
+		update.Snapshot = true
+		return update
+	}
+	update.Events = s.differ.Diff(update.ExploringTree)
+	update.ExploringTree = nil
+	return update
+}
+
+// searchBaseElements are the base elements of the view a search runs on, so
+// the stream can tell complete subtrees.
+func searchBaseElements(base []string, recipePolicy string) []string {
+	view, err := models.GetGraphView(base, recipePolicy)
+	if err != nil {
+		return nil
+	}
+	return view.BaseElements
+}
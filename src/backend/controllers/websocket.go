@@ -1,135 +1,441 @@
 package controllers
 
 import (
+	"ccp/backend/logging"
+	"ccp/backend/metrics"
 	"ccp/backend/models"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type RecipeTreeRequest struct {
-	Target       string `json:"target"`
-	Mode         string `json:"mode"`
-	MaxTreeCount int    `json:"max_tree_count"`
-	DelayMs      int    `json:"delay_ms"`
+	Target       string   `json:"target"`
+	Mode         string   `json:"mode"`
+	MaxTreeCount int      `json:"max_tree_count"`
+	DelayMs      int      `json:"delay_ms"`
+	Base         []string `json:"base,omitempty"`
+	RecipePolicy string   `json:"recipe_policy,omitempty"`
+	// Skip the result cache and run the search
+	NoCache bool `json:"no_cache,omitempty"`
+	// Stream the recorded exploration of a cached result, by default when
+	// updates are streamed
+	Replay *bool `json:"replay,omitempty"`
+	// Replay the recorded updates of a saved search instead of searching,
+	// at speed times the recorded pace or delay_ms apart
+	ReplaySearch string  `json:"replay_search,omitempty"`
+	Speed        float64 `json:"speed,omitempty"`
+	// Updates between two full snapshots on ProtocolV2
+	SnapshotEvery int `json:"snapshot_every,omitempty"`
+	// StreamAll or StreamLatest. Exploration updates are sent when stream or
+	// delay_ms is set, delay_ms apart
+	Stream string `json:"stream,omitempty"`
+	// Time between progress frames, 1s by default, negative for none
+	ProgressMs int `json:"progress_ms,omitempty"`
+}
+
+func (req RecipeTreeRequest) streamsUpdates() bool {
+	return req.DelayMs > 0 || req.Stream != ""
+}
+
+func (req RecipeTreeRequest) progressInterval() time.Duration {
+	if req.ProgressMs < 0 {
+		return 0
+	}
+	if req.ProgressMs == 0 {
+		return defaultProgressInterval
+	}
+	return time.Duration(req.ProgressMs) * time.Millisecond
+}
+
+func (req RecipeTreeRequest) cacheOptions(recorder *models.EventRecorder) models.CacheOptions {
+	replay := req.streamsUpdates()
+	if req.Replay != nil {
+		replay = *req.Replay
+	}
+	return models.CacheOptions{Bypass: req.NoCache, Replay: replay, Recorder: recorder}
 }
 
 type TreeUpdate struct {
-	ExploringTree *models.RecipeTreeNode `json:"exploring_tree"`
+	// The whole tree, on ProtocolV2 only in snapshots
+	ExploringTree *models.RecipeTreeNode `json:"exploring_tree,omitempty"`
 	DurationMs    int                    `json:"duration_ms"`
 	NodesExplored int32                  `json:"nodes_explored"`
+	// ProtocolV2 only: the frame number in the search, whether the frame is a
+	// snapshot and otherwise the changes since the previous frame
+	Seq      int                `json:"seq,omitempty"`
+	Snapshot bool               `json:"snapshot,omitempty"`
+	Events   []models.TreeEvent `json:"events,omitempty"`
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin:     func(r *http.Request) bool { return true },
+	Subprotocols:    []string{ProtocolV2, ProtocolV1},
 }
 
+// Status of a FinalResponse whose search ran into a limit
+const StatusLimitExceeded = "limit_exceeded"
+
 type FinalResponse struct {
 	Trees         []*models.RecipeTreeNode `json:"trees"`
 	DurationMs    int                      `json:"duration_ms"`
 	NodesExplored int32                    `json:"nodes_explored"`
+	// Set when a search limit stopped the search, Trees has what it found
+	Status        string                     `json:"status,omitempty"`
+	LimitExceeded *models.LimitExceededError `json:"limit_exceeded,omitempty"`
+	Error         string                     `json:"error,omitempty"`
+	// Whether the trees came from the result cache
+	Cache *models.CacheInfo `json:"cache,omitempty"`
+	// Id of the saved search, for GET /api/searches/{id}
+	SearchID string `json:"search_id,omitempty"`
+	// Set when the response ends a replay
+	Replay *ReplayState `json:"replay,omitempty"`
+}
+
+// ErrorResponse ends a search that failed, or is sent for a request that
+// could not be handled.
+type ErrorResponse struct {
+	Error        string `json:"error"`
+	ShuttingDown bool   `json:"shutting_down,omitempty"`
+}
+
+// limitExceededResponse is the final response of a search stopped by limit.
+func limitExceededResponse(limit *models.LimitExceededError, trees []*models.RecipeTreeNode, durationMs int, nodesExplored int32) FinalResponse {
+	if trees == nil {
+		trees = []*models.RecipeTreeNode{}
+	}
+	return FinalResponse{
+		Trees:         trees,
+		DurationMs:    durationMs,
+		NodesExplored: nodesExplored,
+		Status:        StatusLimitExceeded,
+		LimitExceeded: limit,
+		Error:         limit.Error(),
+	}
 }
 
 func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Upgrade error:", err)
+		slog.Warn("WebSocket upgrade failed", "error", err)
 		return
 	}
 	defer conn.Close()
+	metrics.WebSocketConnections.Inc()
+	defer metrics.WebSocketConnections.Dec()
+
+	// The server's read and write timeouts are for plain requests, a search
+	// connection stays open for as long as the client wants
+	conn.NetConn().SetDeadline(time.Time{})
 
 	var writeMu sync.Mutex
+	client := &wsClient{conn: conn, writeMu: &writeMu}
+	addClient(client)
+	defer removeClient(client)
+	baseURL := assetBaseURL(r)
+	protocol := conn.Subprotocol()
+	// Searches on this connection are numbered after the connection's request id
+	connID := logging.RequestID(r.Context())
+	if connID == "" {
+		connID = logging.NewRequestID()
+	}
+	searchCount := 0
+	ip := clientIP(r)
+	// The replay running on this connection, if any
+	var replay *replaySession
+	defer func() {
+		if replay != nil {
+			replay.stop()
+		}
+	}()
 
-	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			log.Println("Read error:", err)
-			break
+	// Messages are read while a search runs, so a closed connection cancels
+	// its search right away instead of once the search is done
+	connCtx, cancel := context.WithCancel(searchCtx)
+	defer cancel()
+	msgs := make(chan []byte, 16)
+	go func() {
+		defer close(msgs)
+		defer cancel()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				// Connections closed by shutdown end here too
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && !errors.Is(err, net.ErrClosed) {
+					slog.Warn("WebSocket read error", "request_id", connID, "error", err)
+				}
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-connCtx.Done():
+				return
+			}
+		}
+	}()
+
+	for msg := range msgs {
+		var control ReplayControl
+		if err := json.Unmarshal(msg, &control); err == nil && control.Control != "" {
+			if replay == nil || !replay.control(control) {
+				writeMu.Lock()
+				conn.WriteJSON(map[string]string{"error": "no replay running"})
+				writeMu.Unlock()
+			}
+			continue
 		}
 
 		var req RecipeTreeRequest
 		if err := json.Unmarshal(msg, &req); err != nil {
-			log.Println("Invalid JSON format:", err)
+			slog.Warn("Invalid search request", "request_id", connID, "error", err)
 			continue
 		}
 
-		updateChan := make(chan TreeUpdate, 1000)
-		var latestUpdate *TreeUpdate
-		var updateMu sync.Mutex
-
-		signallerFn := func(
-			exploringTree *models.RecipeTreeNode,
-			durationMs int,
-			nodesExplored int32,
-		) {
-			if req.DelayMs > 0 {
-				updateMu.Lock()
-				latestUpdate = &TreeUpdate{
-					ExploringTree: exploringTree,
-					DurationMs:    durationMs,
-					NodesExplored: nodesExplored,
-				}
-				updateMu.Unlock()
-
-				select {
-				case updateChan <- *latestUpdate:
-				default:
-				}
+		// A new search or replay ends the running replay
+		if replay != nil {
+			replay.stop()
+			replay = nil
+		}
+		if req.ReplaySearch != "" {
+			if replay, err = startReplay(client, req, baseURL, protocol); err != nil {
+				writeMu.Lock()
+				conn.WriteJSON(map[string]string{"error": err.Error()})
+				writeMu.Unlock()
 			}
+			continue
 		}
 
-		var updateWg sync.WaitGroup
-
-		if req.DelayMs > 0 {
-			updateWg.Add(1)
-			go func() {
-				defer updateWg.Done()
-				ticker := time.NewTicker(time.Duration(req.DelayMs) * time.Millisecond)
-				defer ticker.Stop()
-
-				for update := range updateChan {
-					<-ticker.C
-					writeMu.Lock()
-					if err := conn.WriteJSON(update); err != nil {
-						log.Println("Write error:", err)
-						writeMu.Unlock()
-						return
-					}
-					writeMu.Unlock()
-				}
-			}()
+		if !startSearch() {
+			writeMu.Lock()
+			conn.WriteJSON(ErrorResponse{Error: ShutdownMessage, ShuttingDown: true})
+			writeMu.Unlock()
+			continue
+		}
+		release, limitErr := admitSearch(ip)
+		if limitErr != nil {
+			metrics.SearchesLimited.WithLabelValues(limitErr.Limit).Inc()
+			writeMu.Lock()
+			conn.WriteJSON(limitExceededResponse(limitErr, nil, 0, 0))
+			writeMu.Unlock()
+			finishSearch()
+			continue
 		}
-		globalStartTime := time.Now()
-		globalNodeCount := int32(0)
-		trees, err := models.GenerateRecipeTree(req.Target, req.Mode, req.MaxTreeCount, signallerFn, req.DelayMs, globalStartTime, &globalNodeCount)
 
-		close(updateChan)
-		updateWg.Wait()
+		searchCount++
+		searchID := fmt.Sprintf("%s-%d", connID, searchCount)
+		final := runSearch(connCtx, req, searchID, baseURL, protocol, release, func(frame any) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			return conn.WriteJSON(frame)
+		})
 
+		// Shutdown waits until the result is written
 		writeMu.Lock()
+		err := conn.WriteJSON(final)
+		writeMu.Unlock()
+		finishSearch()
 		if err != nil {
-			conn.WriteJSON(map[string]string{"error": err.Error()})
-			writeMu.Unlock()
-			continue
+			slog.Warn("Final write error", "request_id", searchID, "error", err)
+			break
 		}
+	}
+}
 
-		if err := conn.WriteJSON(
-			FinalResponse{
-				Trees:         trees,
-				DurationMs:    int(time.Since(globalStartTime).Milliseconds()),
-				NodesExplored: globalNodeCount,
-			},
-		); err != nil {
-			log.Println("Final write error:", err)
-			writeMu.Unlock()
-			break
+// runSearch runs req for the WebSocket and the event stream alike. Its
+// updates and progress frames go to write while it runs, the frame that ends
+// it is returned: a FinalResponse or an ErrorResponse. release frees the
+// search's admission once the search itself is done.
+func runSearch(
+	ctx context.Context,
+	req RecipeTreeRequest,
+	searchID string,
+	baseURL string,
+	protocol string,
+	release func(),
+	write func(frame any) error,
+) any {
+	globalStartTime := time.Now()
+	globalNodeCount := int32(0)
+	recorder := models.NewEventRecorder(globalStartTime)
+	progress := &models.SearchProgress{}
+
+	// The search runs at full speed, the streamer sends its updates at the
+	// pace the client asked for
+	streamer := newUpdateStreamer(
+		req.Stream,
+		time.Duration(req.DelayMs)*time.Millisecond,
+		req.progressInterval(),
+		newTreeStream(protocol, searchBaseElements(req.Base, req.RecipePolicy), req.SnapshotEvery),
+		func() SearchProgressInfo {
+			return SearchProgressInfo{
+				NodesExplored: atomic.LoadInt32(&globalNodeCount),
+				Frontier:      progress.Frontier(),
+				TreesFound:    progress.TreesFound(),
+				ElapsedMs:     time.Since(globalStartTime).Milliseconds(),
+			}
+		},
+		write,
+	)
+
+	signallerFn := func(
+		exploringTree *models.RecipeTreeNode,
+		durationMs int,
+		nodesExplored int32,
+	) {
+		if req.streamsUpdates() {
+			streamer.push(TreeUpdate{
+				ExploringTree: models.ResolveTreeAssets(exploringTree, baseURL),
+				DurationMs:    durationMs,
+				NodesExplored: nodesExplored,
+			})
 		}
-		writeMu.Unlock()
 	}
+
+	metrics.SearchesStarted.WithLabelValues(metrics.ModeLabel(req.Mode)).Inc()
+
+	ctx = models.WithSearchProgress(ctx, progress)
+	trees, target, cacheInfo, err := models.GenerateRecipeTreeCached(ctx, req.Target, req.Mode, req.MaxTreeCount, req.Base, req.RecipePolicy, signallerFn, globalStartTime, &globalNodeCount, req.cacheOptions(recorder))
+	durationMs := int(time.Since(globalStartTime).Milliseconds())
+	release()
+	if cacheInfo != nil && cacheInfo.Hit {
+		atomic.StoreInt32(&globalNodeCount, cacheInfo.NodesExplored)
+	}
+	nodesExplored := atomic.LoadInt32(&globalNodeCount)
+	// Saved searches and logs name the element, however the request spelled it
+	req.Target = target
+	recordSearch(searchID, req, globalStartTime, nodesExplored, trees, cacheInfo, err)
+
+	// The result follows the last update
+	streamer.finish(ctx)
+
+	var limitErr *models.LimitExceededError
+	if err != nil && !errors.As(err, &limitErr) {
+		return ErrorResponse{Error: err.Error()}
+	}
+
+	// Completed searches are saved with their asset keys, GET
+	// /api/searches/{id} resolves them for whoever opens the link
+	savedID := ""
+	if err == nil {
+		savedID = saveSearch(req, trees, durationMs, nodesExplored, recorder, cacheInfo)
+	}
+
+	for i, tree := range trees {
+		trees[i] = models.ResolveTreeAssets(tree, baseURL)
+	}
+
+	response := FinalResponse{
+		Trees:         trees,
+		DurationMs:    durationMs,
+		NodesExplored: nodesExplored,
+	}
+	if limitErr != nil {
+		response = limitExceededResponse(limitErr, trees, response.DurationMs, response.NodesExplored)
+	}
+	response.Cache = cacheInfo
+	response.SearchID = savedID
+	return response
+}
+
+// saveSearch saves a completed search with the updates recorder got and
+// returns its id, or "" when it could not be saved. A result from the cache
+// is not saved again, it links to the saved search that filled the cache.
+func saveSearch(req RecipeTreeRequest, trees []*models.RecipeTreeNode, durationMs int, nodesExplored int32, recorder *models.EventRecorder, cacheInfo *models.CacheInfo) string {
+	if cacheInfo != nil && cacheInfo.Hit {
+		if id := cacheInfo.SavedSearchID(); id != "" && models.SavedSearchExists(id) {
+			return id
+		}
+		return ""
+	}
+
+	events, truncated := recorder.Events()
+	id, err := models.SaveSearch(&models.SavedSearch{
+		SearchSummary: models.SearchSummary{
+			Target:        req.Target,
+			Mode:          req.Mode,
+			MaxTreeCount:  req.MaxTreeCount,
+			Base:          req.Base,
+			RecipePolicy:  req.RecipePolicy,
+			DurationMs:    durationMs,
+			NodesExplored: nodesExplored,
+			// Replays of a truncated log end before the search did
+			EventsTruncated: truncated,
+		},
+		Trees: trees,
+	}, events)
+	if err != nil {
+		slog.Error("Failed to save search", "target", req.Target, "error", err)
+		return ""
+	}
+	cacheInfo.SetSavedSearchID(id)
+	return id
+}
+
+// recordSearch reports a finished search to the metrics, the log and the
+// search audit log.
+func recordSearch(
+	searchID string,
+	req RecipeTreeRequest,
+	startTime time.Time,
+	nodesExplored int32,
+	trees []*models.RecipeTreeNode,
+	cacheInfo *models.CacheInfo,
+	err error,
+) {
+	mode := metrics.ModeLabel(req.Mode)
+	duration := time.Since(startTime)
+	metrics.SearchDuration.WithLabelValues(mode).Observe(duration.Seconds())
+	metrics.SearchNodesExplored.WithLabelValues(mode).Observe(float64(nodesExplored))
+
+	record := logging.SearchAudit{
+		Time:          startTime.UTC(),
+		RequestID:     searchID,
+		Target:        req.Target,
+		Mode:          req.Mode,
+		MaxTreeCount:  req.MaxTreeCount,
+		Base:          req.Base,
+		RecipePolicy:  req.RecipePolicy,
+		DurationMs:    duration.Milliseconds(),
+		NodesExplored: nodesExplored,
+		Trees:         len(trees),
+		Outcome:       logging.SearchCompleted,
+		CacheHit:      cacheInfo != nil && cacheInfo.Hit,
+	}
+	var limitErr *models.LimitExceededError
+	if errors.As(err, &limitErr) {
+		metrics.SearchesLimited.WithLabelValues(limitErr.Limit).Inc()
+		metrics.TreesReturned.WithLabelValues(mode).Add(float64(len(trees)))
+		record.Outcome = logging.SearchLimitExceeded
+		record.Error = err.Error()
+	} else if err != nil {
+		metrics.SearchesFailed.WithLabelValues(mode).Inc()
+		record.Outcome = logging.SearchFailed
+		record.Error = err.Error()
+	} else {
+		metrics.SearchesCompleted.WithLabelValues(mode).Inc()
+		metrics.TreesReturned.WithLabelValues(mode).Add(float64(len(trees)))
+	}
+
+	slog.Info("search",
+		"request_id", searchID,
+		"target", req.Target,
+		"mode", req.Mode,
+		"duration_ms", record.DurationMs,
+		"nodes_explored", nodesExplored,
+		"trees", record.Trees,
+		"outcome", record.Outcome,
+		"cache_hit", record.CacheHit,
+	)
+	logging.WriteSearchAudit(record)
 }
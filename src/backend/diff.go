@@ -0,0 +1,87 @@
+package main
+
+import (
+	"ccp/backend/models"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runDiff implements `backend diff [-json] <from> <to>`, comparing two
+// element files and printing what changed between them.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the diff as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: backend diff [-json] <from.json> <to.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	diff, err := models.DiffDatasetFiles(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to diff datasets:", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(diff)
+		return 0
+	}
+
+	printDiff(diff)
+	return 0
+}
+
+func printDiff(diff *models.DatasetDiff) {
+	fmt.Printf("Comparing %s -> %s\n", diff.From, diff.To)
+	if diff.IsEmpty() {
+		fmt.Println("No changes")
+		return
+	}
+
+	fmt.Printf("\nAdded elements (%d)\n", len(diff.AddedElements))
+	for _, name := range diff.AddedElements {
+		fmt.Printf("  + %s\n", name)
+	}
+	fmt.Printf("\nRemoved elements (%d)\n", len(diff.RemovedElements))
+	for _, name := range diff.RemovedElements {
+		fmt.Printf("  - %s\n", name)
+	}
+	fmt.Printf("\nAdded recipes (%d)\n", len(diff.AddedRecipes))
+	for _, r := range diff.AddedRecipes {
+		fmt.Printf("  + %s + %s => %s\n", r.Ingredients[0], r.Ingredients[1], r.Element)
+	}
+	fmt.Printf("\nRemoved recipes (%d)\n", len(diff.RemovedRecipes))
+	for _, r := range diff.RemovedRecipes {
+		fmt.Printf("  - %s + %s => %s\n", r.Ingredients[0], r.Ingredients[1], r.Element)
+	}
+	fmt.Printf("\nTier changes (%d)\n", len(diff.TierChanges))
+	for _, c := range diff.TierChanges {
+		fmt.Printf("  %s: %d -> %d\n", c.Element, c.From, c.To)
+	}
+	fmt.Printf("\nImage changes (%d)\n", len(diff.ImageChanges))
+	for _, c := range diff.ImageChanges {
+		if c.From == c.To {
+			fmt.Printf("  %s: %s changed (%.8s -> %.8s)\n", c.Element, c.To, c.FromHash, c.ToHash)
+		} else {
+			fmt.Printf("  %s: %s -> %s\n", c.Element, c.From, c.To)
+		}
+	}
+	fmt.Printf("\nReachability changes (%d)\n", len(diff.ReachabilityChanges))
+	for _, c := range diff.ReachabilityChanges {
+		if c.ToReachable {
+			fmt.Printf("  %s: now reachable\n", c.Element)
+		} else {
+			fmt.Printf("  %s: no longer reachable\n", c.Element)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited search
+const (
+	SearchCompleted     = "completed"
+	SearchFailed        = "failed"
+	SearchLimitExceeded = "limit_exceeded" // Stopped by a search limit, Trees were still returned
+)
+
+// SearchAudit is one line of the search audit log.
+type SearchAudit struct {
+	Time          time.Time `json:"time"`
+	RequestID     string    `json:"request_id"`
+	Target        string    `json:"target"`
+	Mode          string    `json:"mode"`
+	MaxTreeCount  int       `json:"max_tree_count"`
+	Base          []string  `json:"base,omitempty"`
+	RecipePolicy  string    `json:"recipe_policy,omitempty"`
+	DurationMs    int64     `json:"duration_ms"`
+	NodesExplored int32     `json:"nodes_explored"`
+	Trees         int       `json:"trees"`
+	Outcome       string    `json:"outcome"`
+	CacheHit      bool      `json:"cache_hit,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// AuditLogPath is the JSON lines file searches are recorded in, overridable
+// with AUDIT_LOG_PATH. Set it to "off" to disable the audit log.
+func AuditLogPath() string {
+	if path := os.Getenv("AUDIT_LOG_PATH"); path != "" {
+		return path
+	}
+	return "./logs/audit.jsonl"
+}
+
+var (
+	auditMu   sync.Mutex
+	auditFile *os.File
+)
+
+// WriteSearchAudit appends record to the audit log. Failures are logged and
+// never fail the search.
+func WriteSearchAudit(record SearchAudit) {
+	path := AuditLogPath()
+	if path == "off" {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if auditFile == nil {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			slog.Error("Failed to create audit log directory", "path", path, "error", err)
+			return
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			slog.Error("Failed to open audit log", "path", path, "error", err)
+			return
+		}
+		auditFile = file
+	}
+
+	if err := json.NewEncoder(auditFile).Encode(record); err != nil {
+		slog.Error("Failed to write audit log", "path", path, "error", err)
+	}
+}
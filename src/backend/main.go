@@ -1,11 +1,15 @@
 package main
 
 import (
+	"ccp/backend/logging"
 	"ccp/backend/models"
 	"ccp/backend/routes"
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
@@ -15,7 +19,8 @@ func withCORS(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-Id")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -26,15 +31,49 @@ func withCORS(handler http.Handler) http.Handler {
 
 func main() {
 	godotenv.Load()
+	logging.Setup()
+
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
+	config, err := serverConfigFromEnv()
+	if err != nil {
+		slog.Error("Invalid server configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// SIGINT and SIGTERM shut the server down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	searchLimits, err := models.SearchLimitsFromEnv()
+	if err != nil {
+		slog.Error("Invalid search limits", "error", err)
+		os.Exit(1)
+	}
+	models.SetSearchLimits(searchLimits)
+
 	models.Init()
+
+	// Re-scrape the dataset in the background when SCRAPE_INTERVAL is set
+	scrapeConfig, err := models.ScrapeConfigFromEnv()
+	if err != nil {
+		slog.Error("Invalid scrape configuration", "error", err)
+		os.Exit(1)
+	}
+	models.StartScrapeScheduler(ctx, scrapeConfig)
+
 	mux := http.NewServeMux()
 
 	// models.Debug(models.ElementsGraph, -1, true)
 	routes.RegisterRoutes(mux)
 
-	// Wrap all routes with CORS
-	handlerWithCORS := withCORS(mux)
+	// Wrap all routes with CORS and log every request
+	handler := logging.AccessLog(withCORS(mux))
 
-	fmt.Println("Server started on :4000")
-	log.Fatal(http.ListenAndServe("0.0.0.0:4000", handlerWithCORS))
+	if err := serve(ctx, config, handler); err != nil {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
 }
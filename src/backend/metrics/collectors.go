@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics exposed at /metrics
+var (
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ccp_websocket_connections",
+		Help: "WebSocket connections currently open.",
+	})
+
+	SearchesStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ccp_searches_started_total",
+		Help: "Recipe searches started, by mode.",
+	}, []string{"mode"})
+	SearchesCompleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ccp_searches_completed_total",
+		Help: "Recipe searches that returned at least one tree, by mode.",
+	}, []string{"mode"})
+	SearchesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ccp_searches_failed_total",
+		Help: "Recipe searches that ended with an error, by mode.",
+	}, []string{"mode"})
+	SearchesLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ccp_searches_limited_total",
+		Help: "Recipe searches refused or stopped by a search limit, by limit.",
+	}, []string{"limit"})
+	SearchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ccp_search_duration_seconds",
+		Help:    "Time spent in a recipe search, by mode.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"mode"})
+	SearchNodesExplored = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ccp_search_nodes_explored",
+		Help:    "Nodes explored by a recipe search, by mode.",
+		Buckets: prometheus.ExponentialBuckets(10, 4, 10),
+	}, []string{"mode"})
+	TreesReturned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ccp_search_trees_returned_total",
+		Help: "Recipe trees returned by completed searches, by mode.",
+	}, []string{"mode"})
+
+	SearchCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_search_cache_hits_total",
+		Help: "Searches answered from the result cache.",
+	})
+	SearchCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_search_cache_misses_total",
+		Help: "Cacheable searches that had to run.",
+	})
+	SearchCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ccp_search_cache_entries",
+		Help: "Search results in the result cache.",
+	})
+	SearchCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ccp_search_cache_bytes",
+		Help: "Approximate size of the result cache.",
+	})
+
+	UpdatesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_stream_updates_sent_total",
+		Help: "Exploration updates written to clients.",
+	})
+	UpdatesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_stream_updates_dropped_total",
+		Help: "Exploration updates not sent, replaced by a newer one in latest-only streams or lost buffering, or still queued when the client went away.",
+	})
+	UpdatesSpilled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_stream_updates_spilled_total",
+		Help: "Exploration updates buffered on disk because the client was behind.",
+	})
+
+	GraphElements = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ccp_graph_elements",
+		Help: "Elements in the live graph.",
+	})
+	GraphRecipes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ccp_graph_recipes",
+		Help: "Recipes in the live graph.",
+	})
+	GraphReloads = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_graph_reloads_total",
+		Help: "Times the graph was built from a dataset, including the first load.",
+	})
+	GraphEdits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccp_graph_edits_total",
+		Help: "Recipe and element edits applied to the live graph.",
+	})
+)
@@ -0,0 +1,25 @@
+// Package metrics defines the Prometheus metrics of the backend. They are
+// registered with the default registry and served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the metrics in the Prometheus exposition format, along with
+// the Go runtime and process metrics of the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ModeLabel keeps the mode label to the known search modes so bad requests
+// cannot grow the number of series.
+func ModeLabel(mode string) string {
+	switch mode {
+	case "bfs", "dfs", "bidirectional":
+		return mode
+	}
+	return "invalid"
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// nameIndex maps folded element names and aliases to element names, it is
+// rebuilt together with the graph and guarded by graphMu.
+var nameIndex = make(map[string]string)
+
+// AliasesPath is a JSON object mapping other spellings to element names,
+// overridable with ALIASES_PATH. It is optional and extends the aliases of
+// the dataset.
+func AliasesPath() string {
+	if path := os.Getenv("ALIASES_PATH"); path != "" {
+		return path
+	}
+	return "./data/aliases.json"
+}
+
+func loadAliasesFile(filePath string) (map[string]string, error) {
+	aliases := map[string]string{}
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return aliases, nil
+}
+
+// foldName ignores case and extra whitespace, so "lava", "LAVA" and "Lava "
+// all fold to the same key.
+func foldName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// buildNameIndex indexes every element name, then the aliases of the loaded
+// dataset and of AliasesPath. An alias never hides an element name.
+func buildNameIndex() {
+	nameIndex = make(map[string]string, len(nameToNode))
+	for name := range nameToNode {
+		nameIndex[foldName(name)] = name
+	}
+
+	aliases := map[string]string{}
+	if loadedDataset != nil {
+		for alias, name := range loadedDataset.Aliases {
+			aliases[alias] = name
+		}
+	}
+	userAliases, err := loadAliasesFile(AliasesPath())
+	if err != nil {
+		slog.Warn("Failed to load aliases", "error", err)
+	}
+	for alias, name := range userAliases {
+		aliases[alias] = name
+	}
+
+	for alias, name := range aliases {
+		key := foldName(alias)
+		if _, ok := nameIndex[key]; ok {
+			continue
+		}
+		if _, ok := nameToNode[name]; !ok {
+			// Aliases may also be written with any capitalization of the name
+			if name, ok = nameIndex[foldName(name)]; !ok {
+				slog.Warn("Alias refers to an element not in the graph", "alias", alias, "element", name)
+				continue
+			}
+		}
+		nameIndex[key] = name
+	}
+}
+
+// resolveElementName returns the element name that name spells or is an
+// alias of. The caller holds graphMu.
+func resolveElementName(name string) (string, bool) {
+	if _, ok := nameToNode[name]; ok {
+		return name, true
+	}
+	canonical, ok := nameIndex[foldName(name)]
+	if !ok {
+		return "", false
+	}
+	_, ok = nameToNode[canonical]
+	return canonical, ok
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Image paths are stored as asset keys: the path of the file relative to
+// the public directory with forward slashes and no escaping, e.g. "Fire.png"
+// or "thumbs/Acid_rain.png". They are served by the /assets/ route.
+
+// PublicDir holds the assets, overridable with PUBLIC_DIR.
+func PublicDir() string {
+	if dir := os.Getenv("PUBLIC_DIR"); dir != "" {
+		return dir
+	}
+	return "./public"
+}
+
+// BaseURL is BASE_URL with a trailing slash, or empty when it is unset and
+// URLs are built from the request instead.
+func BaseURL() string {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL != "" && !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return baseURL
+}
+
+// AssetKey turns an image path in any of the forms older scrapes wrote
+// ("../backend/public/Fire.png", "/public/Acid%20rain.png") into an asset key.
+// Absolute URLs are kept as they are.
+func AssetKey(imagePath string) string {
+	if imagePath == "" || strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://") {
+		return imagePath
+	}
+
+	key := strings.ReplaceAll(imagePath, "\\", "/")
+	key = strings.TrimPrefix(key, "../backend/")
+	if strings.HasPrefix(key, "/public/") {
+		if unescaped, err := url.PathUnescape(key); err == nil {
+			key = unescaped
+		}
+	}
+	key = strings.TrimPrefix(key, "/")
+	key = strings.TrimPrefix(key, "public/")
+	return path.Clean(key)
+}
+
+// AssetURL is the URL of key under baseURL, or a root-relative URL when
+// baseURL is empty.
+func AssetURL(baseURL string, key string) string {
+	if key == "" || strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+		return key
+	}
+	if baseURL == "" {
+		baseURL = "/"
+	}
+	return baseURL + "assets/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// GetImagePath is the URL of an image path when nothing is known about the
+// request, relative when BASE_URL is unset. Trees built with it are made
+// absolute per request with ResolveTreeAssets.
+func GetImagePath(imagePath string) string {
+	return AssetURL(BaseURL(), AssetKey(imagePath))
+}
+
+// ResolveTreeAssets returns tree with root-relative image URLs prefixed by
+// baseURL. Trees share subtrees between searches, so the tree is copied
+// instead of changed in place. With BASE_URL set the URLs are already absolute.
+func ResolveTreeAssets(tree *RecipeTreeNode, baseURL string) *RecipeTreeNode {
+	if baseURL == "" || baseURL == BaseURL() {
+		return tree
+	}
+	return resolveTreeAssets(tree, baseURL)
+}
+
+func resolveTreeAssets(tree *RecipeTreeNode, baseURL string) *RecipeTreeNode {
+	if tree == nil {
+		return nil
+	}
+	resolved := &RecipeTreeNode{
+		Name:      tree.Name,
+		ImagePath: tree.ImagePath,
+		Element1:  resolveTreeAssets(tree.Element1, baseURL),
+		Element2:  resolveTreeAssets(tree.Element2, baseURL),
+	}
+	if strings.HasPrefix(resolved.ImagePath, "/") {
+		resolved.ImagePath = baseURL + strings.TrimPrefix(resolved.ImagePath, "/")
+	}
+	return resolved
+}
+
+// AssetFile returns the file of key inside the public directory. Keys that
+// would leave the directory are rejected.
+func AssetFile(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid asset key: %s", key)
+	}
+	return filepath.Join(PublicDir(), filepath.FromSlash(key)), nil
+}
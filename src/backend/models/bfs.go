@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -9,20 +10,21 @@ import (
 
 // Fungsi utama algoritma BFS
 func BFSFindTrees(
+	ctx context.Context,
 	targetGraphNode *ElementsGraphNode,
 	maxTreeCount int,
 	signalTreeChange func(*RecipeTreeNode, int, int32),
 	globalStartTime time.Time,
 	globalNodeCounter *int32,
-	delayMs int,
+	view *GraphView,
 ) ([]*RecipeTreeNode, error) {
 	// Validasi awal apakah node target valid
 	if targetGraphNode == nil {
 		return nil, fmt.Errorf("targetGraphNode is nil")
 	}
 
-	// Jika node merupakan base element atau tidak memiliki resep, langsung return sebagai hasil
-	if len(targetGraphNode.RecipesToMakeThisElement) == 0 || IsBaseElement(targetGraphNode.Name) {
+	// Jika node merupakan base element pada view, langsung return sebagai hasil
+	if view.IsBaseElement(targetGraphNode.Name) {
 		node := &RecipeTreeNode{
 			Name:      targetGraphNode.Name,
 			ImagePath: GetImagePath(targetGraphNode.ImagePath),
@@ -40,121 +42,130 @@ func BFSFindTrees(
 
 	// Struktur queue BFS untuk menyimpan state saat traversal
 	type QueueItem struct {
-		ElementName string
-		Level       int
-		TreeSoFar   *RecipeTreeNode
-		IsComplete  bool
+		Element *ElementsGraphNode
+		Level   int // Jarak dari target, sama dengan panjang path pada DFS
 	}
 
 	// Iterasi setiap resep dari target node
-	for _, recipe := range targetGraphNode.RecipesToMakeThisElement {
+	for _, recipe := range view.RecipesFor(targetGraphNode) {
+		// Lewati resep target yang memakai target itu sendiri
+		if recipe.ElementOne == targetGraphNode || recipe.ElementTwo == targetGraphNode {
+			continue
+		}
+
 		wg.Add(1)
 		go func(r *Recipe) {
 			defer wg.Done()
 
-			// Map untuk menyimpan semua tree parsial yang berhasil dibentuk per elemen
-			elementToTrees := make(map[string][]*RecipeTreeNode)
-			processedElements := make(map[string]bool) // Menandai elemen yang sudah diproses
+			// Tahap 1: BFS dari kedua bahan resep untuk menemukan semua elemen yang
+			// mungkin ada di bawahnya. Target tidak pernah dimasukkan ke queue karena
+			// target tidak boleh ada di dalam tree-nya sendiri
+			ids := map[string]int{targetGraphNode.Name: 0} // Nomor setiap elemen yang ditemukan
+			var elements []*ElementsGraphNode              // Elemen non-base dalam urutan BFS
+			elementToTrees := make(map[string][]*bfsTree)  // Tree parsial yang sudah dibentuk per elemen
 			queue := make([]*QueueItem, 0)
 
+			enqueue := func(node *ElementsGraphNode, level int) {
+				if _, seen := ids[node.Name]; seen {
+					return
+				}
+				ids[node.Name] = len(ids)
+				queue = append(queue, &QueueItem{Element: node, Level: level})
+				addFrontier(ctx, 1)
+			}
+			// Item yang tersisa di queue tidak lagi termasuk frontier
+			defer func() { addFrontier(ctx, -len(queue)) }()
+
 			// Memasukkan dua element dari resep ke dalam queue
-			queue = append(
-				queue,
-				&QueueItem{ElementName: r.ElementOne.Name, Level: 1, TreeSoFar: nil, IsComplete: false},
-				&QueueItem{ElementName: r.ElementTwo.Name, Level: 1, TreeSoFar: nil, IsComplete: false},
-			)
+			enqueue(r.ElementOne, 1)
+			enqueue(r.ElementTwo, 1)
 
 			// BFS loop
 			for len(queue) > 0 {
-				if delayMs > 0 {
-					time.Sleep(time.Duration(delayMs) * time.Millisecond)
-				}
-
-				// Batasi jumlah tree yang ditemukan sesuai batas maksimum MaxTreeCount
-				mu.Lock()
-				if treesFound >= maxTreeCount {
-					mu.Unlock()
+				// Hentikan pencarian jika dibatalkan (misalnya server sedang shutdown)
+				if ctx.Err() != nil {
 					return
 				}
-				mu.Unlock()
 
 				item := queue[0]
 				queue = queue[1:]
+				addFrontier(ctx, -1)
 
-				// Continue jika sudah pernah diproses
-				if processedElements[item.ElementName] {
-					continue
-				}
-
-				// Ambil node dari nama elemen
-				elementNode, exists := GetElementsGraphNodeByName(item.ElementName)
-				if !exists || elementNode == nil {
+				// Cabang yang lebih dalam dari batas kedalaman dipangkas
+				if tooDeep(ctx, item.Level) {
 					continue
 				}
 
 				// Tambah counter global eksplorasi node (aman untuk goroutine)
-				atomic.AddInt32(globalNodeCounter, 1)
+				exploreNode(ctx, globalNodeCounter)
 
 				// Jika node adalah base element, buat node tree sederhana
-				if IsBaseElement(elementNode.Name) || len(elementNode.RecipesToMakeThisElement) == 0 {
-					simpleTree := &RecipeTreeNode{
-						Name:      elementNode.Name,
-						ImagePath: GetImagePath(elementNode.ImagePath),
-					}
-					elementToTrees[elementNode.Name] = []*RecipeTreeNode{simpleTree}
-					processedElements[elementNode.Name] = true
+				if view.IsBaseElement(item.Element.Name) {
+					leaf := &bfsTree{node: &RecipeTreeNode{
+						Name:      item.Element.Name,
+						ImagePath: GetImagePath(item.Element.ImagePath),
+					}}
+					leaf.add(ids[item.Element.Name])
+					elementToTrees[item.Element.Name] = []*bfsTree{leaf}
 					continue
 				}
 
-				// Jika belum punya tree, tunggu semua prerequisite selesai diproses
-				if item.TreeSoFar == nil {
-					var allPrereqsProcessed = true
-
-					// Cek apakah semua bahan resep sudah tersedia
-					for _, elementRecipe := range elementNode.RecipesToMakeThisElement {
-						if !processedElements[elementRecipe.ElementOne.Name] ||
-							!processedElements[elementRecipe.ElementTwo.Name] {
-							allPrereqsProcessed = false
-
-							// Tambahkan elemen yang belum tersedia ke antrian
-							if !processedElements[elementRecipe.ElementOne.Name] {
-								queue = append(queue, &QueueItem{
-									ElementName: elementRecipe.ElementOne.Name,
-									Level:       item.Level + 1,
-								})
-							}
-							if !processedElements[elementRecipe.ElementTwo.Name] {
-								queue = append(queue, &QueueItem{
-									ElementName: elementRecipe.ElementTwo.Name,
-									Level:       item.Level + 1,
-								})
-							}
-						}
-					}
+				// Tambahkan bahan-bahan yang belum pernah ditemukan ke antrian
+				elements = append(elements, item.Element)
+				for _, elementRecipe := range view.RecipesFor(item.Element) {
+					enqueue(elementRecipe.ElementOne, item.Level+1)
+					enqueue(elementRecipe.ElementTwo, item.Level+1)
+				}
+			}
 
-					// Jika belum semua bahan tersedia, tunda node saat ini
-					if !allPrereqsProcessed {
-						queue = append(queue, &QueueItem{
-							ElementName: item.ElementName,
-							Level:       item.Level + 10,
-						})
-						continue
-					}
+			// Tahap 2: bentuk tree dari bawah ke atas per tinggi. Pada putaran ke-h
+			// setiap elemen hanya memakai tree bahan dari putaran sebelumnya, sehingga
+			// tree yang lebih pendek selalu ditemukan lebih dulu. Tree bahan yang sudah
+			// memuat elemen itu sendiri membentuk siklus sehingga dilewati, seperti
+			// elemen pada path di DFS. Cukup maxTreeCount subtree per elemen untuk
+			// membentuk maxTreeCount tree root
+			combined := make(map[*Recipe][2]int) // Jumlah tree bahan kiri dan kanan yang sudah dikombinasikan
+			for height := 1; ; height++ {
+				// Tree setinggi ini membuat tree root lebih dalam dari batas kedalaman
+				if tooDeep(ctx, height+1) {
+					break
+				}
 
-					// Semua bahan tersedia, lalu mulai membentuk tree
-					var elementTrees []*RecipeTreeNode
-					for _, elementRecipe := range elementNode.RecipesToMakeThisElement {
-						leftTrees := elementToTrees[elementRecipe.ElementOne.Name]
-						rightTrees := elementToTrees[elementRecipe.ElementTwo.Name]
+				ready := make(map[string]int, len(elementToTrees))
+				for name, trees := range elementToTrees {
+					ready[name] = len(trees)
+				}
 
-						for _, lt := range leftTrees {
-							for _, rt := range rightTrees {
-								newTree := &RecipeTreeNode{
+				progressed := false
+				for _, elementNode := range elements {
+					if ctx.Err() != nil {
+						return
+					}
+					id := ids[elementNode.Name]
+
+				buildTrees:
+					for _, elementRecipe := range view.RecipesFor(elementNode) {
+						leftTrees := elementToTrees[elementRecipe.ElementOne.Name][:ready[elementRecipe.ElementOne.Name]]
+						rightTrees := elementToTrees[elementRecipe.ElementTwo.Name][:ready[elementRecipe.ElementTwo.Name]]
+						done := combined[elementRecipe]
+						combined[elementRecipe] = [2]int{len(leftTrees), len(rightTrees)}
+
+						for i, lt := range leftTrees {
+							for j, rt := range rightTrees {
+								if len(elementToTrees[elementNode.Name]) >= maxTreeCount {
+									break buildTrees
+								}
+								if (i < done[0] && j < done[1]) || lt.has(id) || rt.has(id) {
+									continue
+								}
+								newTree := &bfsTree{node: &RecipeTreeNode{
 									Name:      elementNode.Name,
 									ImagePath: GetImagePath(elementNode.ImagePath),
-									Element1:  lt,
-									Element2:  rt,
-								}
+									Element1:  lt.node,
+									Element2:  rt.node,
+								}}
+								newTree.union(lt, rt)
+								newTree.add(id)
 
 								// Kirim update ExploringTree ke FE Visualizer melalui WebSocket
 								if signalTreeChange != nil {
@@ -164,21 +175,23 @@ func BFSFindTrees(
 											}
 										}()
 										signalTreeChange(
-											newTree,
+											newTree.node,
 											int(time.Since(globalStartTime).Milliseconds()),
 											atomic.LoadInt32(globalNodeCounter),
 										)
 									}()
 								}
 
-								elementTrees = append(elementTrees, newTree)
+								elementToTrees[elementNode.Name] = append(elementToTrees[elementNode.Name], newTree)
+								progressed = true
 							}
 						}
 					}
+				}
 
-					// Simpan hasil tree yang dibentuk dan tandai elemen sebagai telah diproses
-					elementToTrees[elementNode.Name] = elementTrees
-					processedElements[elementNode.Name] = true
+				// Berhenti jika putaran ini tidak menghasilkan tree baru
+				if !progressed {
+					break
 				}
 			}
 
@@ -186,10 +199,6 @@ func BFSFindTrees(
 			leftTrees := elementToTrees[r.ElementOne.Name]
 			rightTrees := elementToTrees[r.ElementTwo.Name]
 
-			if leftTrees == nil || rightTrees == nil {
-				return
-			}
-
 			for _, lt := range leftTrees {
 				for _, rt := range rightTrees {
 					mu.Lock()
@@ -202,12 +211,13 @@ func BFSFindTrees(
 					root := &RecipeTreeNode{
 						Name:      targetGraphNode.Name,
 						ImagePath: GetImagePath(targetGraphNode.ImagePath),
-						Element1:  lt,
-						Element2:  rt,
+						Element1:  lt.node,
+						Element2:  rt.node,
 					}
 
 					treesFound++
 					mu.Unlock()
+					foundTree(ctx)
 
 					// Kirim update ExploringTree ke FE Visualizer melalui WebSocket
 					if signalTreeChange != nil {
@@ -251,3 +261,31 @@ func BFSFindTrees(
 
 	return result, nil
 }
+
+// Tree parsial BFS beserta elemen-elemen yang ada di dalamnya, dinomori per pencarian
+type bfsTree struct {
+	node     *RecipeTreeNode
+	elements []uint64
+}
+
+func (t *bfsTree) has(id int) bool {
+	return id/64 < len(t.elements) && t.elements[id/64]&(1<<(id%64)) != 0
+}
+
+func (t *bfsTree) add(id int) {
+	for len(t.elements) <= id/64 {
+		t.elements = append(t.elements, 0)
+	}
+	t.elements[id/64] |= 1 << (id % 64)
+}
+
+func (t *bfsTree) union(trees ...*bfsTree) {
+	for _, tree := range trees {
+		for len(t.elements) < len(tree.elements) {
+			t.elements = append(t.elements, 0)
+		}
+		for i, word := range tree.elements {
+			t.elements[i] |= word
+		}
+	}
+}
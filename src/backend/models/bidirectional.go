@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -12,21 +13,22 @@ type QueueItem struct {
 
 // Fungsi utama algoritma Bidirectional Search
 func BidirectionalFindTrees(
+	ctx context.Context,
 	rootRecipeTree *RecipeTreeNode,
 	targetGraphNode *ElementsGraphNode,
 	maxTreeCount int,
 	signalTreeChange func(*RecipeTreeNode, int, int32),
 	globalStartTime time.Time,
 	globalNodeCounter *int32,
-	delayMs int,
+	view *GraphView,
 ) ([]*RecipeTreeNode, error) {
 	// Validasi awal apakah graph node target valid
 	if targetGraphNode == nil {
 		return nil, fmt.Errorf("targetGraphNode is nil")
 	}
 
-	// Validasi jika elemen tidak memiliki resep atau merupakan elemen dasar
-	if len(targetGraphNode.RecipesToMakeThisElement) == 0 || IsBaseElement(targetGraphNode.Name) {
+	// Validasi jika elemen merupakan elemen dasar pada view
+	if view.IsBaseElement(targetGraphNode.Name) {
 		node := &RecipeTreeNode{
 			Name:      targetGraphNode.Name,
 			ImagePath: GetImagePath(targetGraphNode.ImagePath),
@@ -44,11 +46,11 @@ func BidirectionalFindTrees(
 	queueUpper := []*QueueItem{{Element: targetGraphNode}}
 
 	// queueLower: proses dimulai dari base elements yang relevan
-	// Pemilihan base elements menggunakan IsThisMadeFrom
+	// Pemilihan base elements menggunakan IsMadeFrom pada view
 	queueLower := []*QueueItem{}
-	for _, base := range GetBaseElements() {
-		if targetGraphNode.IsThisMadeFrom(base) {
-			if node, ok := GetElementsGraphNodeByName(base); ok {
+	for _, base := range view.BaseElements {
+		if view.IsMadeFrom(targetGraphNode, base) {
+			if node, ok := view.Node(base); ok {
 				queueLower = append(queueLower, &QueueItem{Element: node})
 			}
 		}
@@ -56,15 +58,25 @@ func BidirectionalFindTrees(
 
 	var resultTrees []*RecipeTreeNode
 
+	// Frontier pencarian adalah isi kedua queue
+	frontier := len(queueUpper) + len(queueLower)
+	addFrontier(ctx, frontier)
+	updateFrontier := func() {
+		addFrontier(ctx, len(queueUpper)+len(queueLower)-frontier)
+		frontier = len(queueUpper) + len(queueLower)
+	}
+	defer func() { addFrontier(ctx, -frontier) }()
+
 	// Proses utama loop pencarian dua arah
 	for len(queueUpper) > 0 && len(queueLower) > 0 && len(resultTrees) < maxTreeCount {
-		// Delay setiap iterasi untuk visualisasi live update
-		if delayMs > 0 {
-			time.Sleep(time.Duration(delayMs) * time.Millisecond)
+		// Hentikan pencarian jika dibatalkan (shutdown atau batas pencarian),
+		// tree yang sudah ditemukan tetap dikembalikan
+		if err := ctx.Err(); err != nil {
+			return resultTrees, err
 		}
 
 		// Proses pencarian dari arah target menuju base elements
-		nQueueUpper, newUpperNames := processUpper(queueUpper, visitedUpper)
+		nQueueUpper, newUpperNames := processUpper(queueUpper, visitedUpper, view)
 		for _, name := range newUpperNames {
 			// Jika ditemukan pertemuan dan belum diproses sebelumnya
 			if visitedLower[name] && name == targetGraphNode.Name {
@@ -72,9 +84,9 @@ func BidirectionalFindTrees(
 					continue
 				}
 				seenMeeting[name] = true
-				if node, ok := GetElementsGraphNodeByName(name); ok {
+				if node, ok := view.Node(name); ok {
 					// DFS dipanggil setelah upper dan lower bertemu untuk membangun tree secara lengkap
-					treesFromDFS, err := DFSFindTrees(nil, node, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, delayMs)
+					treesFromDFS, err := DFSFindTrees(ctx, nil, node, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, view)
 					if err == nil {
 						resultTrees = appendAllValidTargetTrees(resultTrees, treesFromDFS, targetGraphNode.Name, maxTreeCount)
 						if len(resultTrees) >= maxTreeCount {
@@ -85,21 +97,18 @@ func BidirectionalFindTrees(
 			}
 		}
 		queueUpper = nQueueUpper
-
-		if delayMs > 0 {
-			time.Sleep(time.Duration(delayMs) * time.Millisecond)
-		}
+		updateFrontier()
 
 		// Proses pencarian dari base menuju target
-		nQueueLower, newLowerNames := processLower(queueLower, visitedLower)
+		nQueueLower, newLowerNames := processLower(queueLower, visitedLower, view)
 		for _, name := range newLowerNames {
 			if visitedUpper[name] && name == targetGraphNode.Name {
 				if _, already := seenMeeting[name]; already {
 					continue
 				}
 				seenMeeting[name] = true
-				if node, ok := GetElementsGraphNodeByName(name); ok {
-					treesFromDFS, err := DFSFindTrees(nil, node, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, delayMs)
+				if node, ok := view.Node(name); ok {
+					treesFromDFS, err := DFSFindTrees(ctx, nil, node, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, view)
 					if err == nil {
 						resultTrees = appendAllValidTargetTrees(resultTrees, treesFromDFS, targetGraphNode.Name, maxTreeCount)
 						if len(resultTrees) >= maxTreeCount {
@@ -110,12 +119,13 @@ func BidirectionalFindTrees(
 			}
 		}
 		queueLower = nQueueLower
+		updateFrontier()
 	}
 	return resultTrees, nil
 }
 
 // Helper function untuk memroses queueUpper (pencarian dari target ke base)
-func processUpper(queue []*QueueItem, visited map[string]bool) ([]*QueueItem, []string) {
+func processUpper(queue []*QueueItem, visited map[string]bool, view *GraphView) ([]*QueueItem, []string) {
 	// Queue untuk iterasi selanjutnya
 	nextQueue := []*QueueItem{}
 	// Menyimpan nama-nama node yang dihasilkan
@@ -136,7 +146,7 @@ func processUpper(queue []*QueueItem, visited map[string]bool) ([]*QueueItem, []
 
 		// Proses seluruh resep pembentuk elemen ini
 		// Lanjutkan ke child nodes yang menjadi bahan resep
-		for _, recipe := range node.RecipesToMakeThisElement {
+		for _, recipe := range view.RecipesFor(node) {
 			// Tambahkan kedua elemen bahan ke antrian berikutnya
 			nextQueue = append(nextQueue, &QueueItem{Element: recipe.ElementOne})
 			nextQueue = append(nextQueue, &QueueItem{Element: recipe.ElementTwo})
@@ -147,7 +157,7 @@ func processUpper(queue []*QueueItem, visited map[string]bool) ([]*QueueItem, []
 }
 
 // Helper function untuk memroses queueLower (pencarian dari base ke target)
-func processLower(queue []*QueueItem, visited map[string]bool) ([]*QueueItem, []string) {
+func processLower(queue []*QueueItem, visited map[string]bool, view *GraphView) ([]*QueueItem, []string) {
 	// Queue untuk iterasi selanjutnya
 	nextQueue := []*QueueItem{}
 	// Menyimpan nama-nama node yang dihasilkan
@@ -167,9 +177,9 @@ func processLower(queue []*QueueItem, visited map[string]bool) ([]*QueueItem, []
 		produced = append(produced, node.Name)
 
 		// Proses seluruh elemen yang dapat dibuat dari elemen ini
-		for _, recipe := range node.RecipesToMakeOtherElement {
-			// Ambil elemen hasil dari resep
-			if targetNode, ok := GetElementsGraphNodeByName(recipe.TargetElementName); ok {
+		for _, recipe := range view.RecipesUsing(node) {
+			// Ambil elemen hasil dari resep yang dapat dicapai pada view
+			if targetNode, ok := view.Node(recipe.TargetElementName); ok && view.IsReachable(targetNode.Name) {
 				// Tambahkan elemen hasil ke antrian berikutnya
 				nextQueue = append(nextQueue, &QueueItem{Element: targetNode})
 			}
@@ -220,7 +230,7 @@ func treeToString(tree *RecipeTreeNode) string {
 	return tree.Name + "(" + treeToString(tree.Element1) + "," + treeToString(tree.Element2) + ")"
 }
 
-// Mendapatkan base elements
+// Mendapatkan base elements dari view default
 func GetBaseElements() []string {
 	return baseElements
 }
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DatasetSchemaVersion is the newest dataset format this backend reads and
+// the one it writes. The format is described by data/elements.schema.json.
+const DatasetSchemaVersion = 1
+
+// Dataset is the versioned envelope around the element list written by the
+// scraper. Files holding only a bare element array are still accepted and
+// load as a Dataset with SchemaVersion 0 and no metadata.
+type Dataset struct {
+	SchemaVersion int        `json:"schema_version"`
+	Name          string     `json:"dataset"`
+	Source        string     `json:"source,omitempty"`
+	ScrapedAt     *time.Time `json:"scraped_at,omitempty"`
+	BaseElements  []string   `json:"base_elements,omitempty"`
+	// SHA-256 of every image file, keyed by the image's asset key
+	ImageChecksums map[string]string `json:"image_checksums,omitempty"`
+	// Other spellings and former names, mapped to the element's name
+	Aliases  map[string]string `json:"aliases,omitempty"`
+	Elements []Element         `json:"elements"`
+}
+
+func LoadDatasetFromJSON(filePath string) (*Dataset, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Datasets from before the envelope are a bare array of elements
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var elements []Element
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		dataset := &Dataset{Name: name, Elements: elements}
+		dataset.normalizeAssetKeys()
+		return dataset, nil
+	}
+
+	var dataset Dataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	if err := dataset.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	dataset.normalizeAssetKeys()
+	return &dataset, nil
+}
+
+// normalizeAssetKeys rewrites the image paths of older scrapes to asset keys.
+func (d *Dataset) normalizeAssetKeys() {
+	for i := range d.Elements {
+		el := &d.Elements[i]
+		el.ImagePath = AssetKey(el.ImagePath)
+		el.ThumbPath = AssetKey(el.ThumbPath)
+		el.WebPPath = AssetKey(el.WebPPath)
+	}
+	if d.ImageChecksums != nil {
+		checksums := make(map[string]string, len(d.ImageChecksums))
+		for imagePath, checksum := range d.ImageChecksums {
+			checksums[AssetKey(imagePath)] = checksum
+		}
+		d.ImageChecksums = checksums
+	}
+}
+
+// Validate checks the rules of data/elements.schema.json that decoding alone
+// does not enforce.
+func (d *Dataset) Validate() error {
+	if d.SchemaVersion < 1 || d.SchemaVersion > DatasetSchemaVersion {
+		return fmt.Errorf("unsupported dataset schema_version %d, expected 1 to %d", d.SchemaVersion, DatasetSchemaVersion)
+	}
+	if d.Name == "" {
+		return fmt.Errorf("dataset name must not be empty")
+	}
+	if len(d.Elements) == 0 {
+		return fmt.Errorf("dataset has no elements")
+	}
+
+	names := make(map[string]bool, len(d.Elements))
+	for i, el := range d.Elements {
+		if el.Name == "" {
+			return fmt.Errorf("element %d has no name", i)
+		}
+		if names[el.Name] {
+			return fmt.Errorf("element %s is listed twice", el.Name)
+		}
+		names[el.Name] = true
+
+		for _, recipe := range el.Recipes {
+			if len(recipe) != 2 || recipe[0] == "" || recipe[1] == "" {
+				return fmt.Errorf("element %s has a recipe without exactly 2 ingredients: %v", el.Name, recipe)
+			}
+		}
+	}
+
+	for _, name := range d.BaseElements {
+		if !names[name] {
+			return fmt.Errorf("base element %s is not in the dataset", name)
+		}
+	}
+	for alias, name := range d.Aliases {
+		if strings.TrimSpace(alias) == "" {
+			return fmt.Errorf("alias of %s must not be empty", name)
+		}
+		if !names[name] {
+			return fmt.Errorf("alias %s refers to %s, which is not in the dataset", alias, name)
+		}
+	}
+	return nil
+}
+
+// SaveDatasetToJSON writes dataset in the current schema version.
+func SaveDatasetToJSON(dataset *Dataset, filePath string) error {
+	dataset.SchemaVersion = DatasetSchemaVersion
+	if err := dataset.Validate(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed write never leaves a broken dataset
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dataset); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, filePath)
+}
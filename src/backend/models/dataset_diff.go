@@ -0,0 +1,245 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+type RecipeChange struct {
+	Element     string   `json:"element"`
+	Ingredients []string `json:"ingredients"`
+}
+
+type TierChange struct {
+	Element string `json:"element"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+}
+
+// ImageChange is an image that moved or whose file changed. The hashes are
+// only set when both datasets have one for the element.
+type ImageChange struct {
+	Element  string `json:"element"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	FromHash string `json:"from_hash,omitempty"`
+	ToHash   string `json:"to_hash,omitempty"`
+}
+
+type ReachabilityChange struct {
+	Element       string `json:"element"`
+	FromReachable bool   `json:"from_reachable"`
+	ToReachable   bool   `json:"to_reachable"`
+}
+
+// DatasetDiff lists what changed between two element files. Tier and
+// reachability changes only cover elements present in both files.
+type DatasetDiff struct {
+	From                string               `json:"from"`
+	To                  string               `json:"to"`
+	AddedElements       []string             `json:"added_elements"`
+	RemovedElements     []string             `json:"removed_elements"`
+	AddedRecipes        []RecipeChange       `json:"added_recipes"`
+	RemovedRecipes      []RecipeChange       `json:"removed_recipes"`
+	TierChanges         []TierChange         `json:"tier_changes"`
+	ImageChanges        []ImageChange        `json:"image_changes"`
+	ReachabilityChanges []ReachabilityChange `json:"reachability_changes"`
+}
+
+func (d *DatasetDiff) IsEmpty() bool {
+	return len(d.AddedElements) == 0 && len(d.RemovedElements) == 0 &&
+		len(d.AddedRecipes) == 0 && len(d.RemovedRecipes) == 0 &&
+		len(d.TierChanges) == 0 && len(d.ImageChanges) == 0 &&
+		len(d.ReachabilityChanges) == 0
+}
+
+// DiffDatasetFiles loads two element files and compares them.
+func DiffDatasetFiles(fromPath string, toPath string) (*DatasetDiff, error) {
+	from, err := LoadDatasetFromJSON(fromPath)
+	if err != nil {
+		return nil, err
+	}
+	to, err := LoadDatasetFromJSON(toPath)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffDatasets(from, to)
+	diff.From = fromPath
+	diff.To = toPath
+	return diff, nil
+}
+
+// DiffDatasets compares two datasets. Each side starts from its own base
+// elements plus its recipe-less elements, the same way InitElementsGraph
+// picks them.
+func DiffDatasets(from *Dataset, to *Dataset) *DatasetDiff {
+	diff := &DatasetDiff{
+		AddedElements:       []string{},
+		RemovedElements:     []string{},
+		AddedRecipes:        []RecipeChange{},
+		RemovedRecipes:      []RecipeChange{},
+		TierChanges:         []TierChange{},
+		ImageChanges:        []ImageChange{},
+		ReachabilityChanges: []ReachabilityChange{},
+	}
+
+	fromByName := elementsByName(from.Elements)
+	toByName := elementsByName(to.Elements)
+	fromTiers := elementTiers(fromByName, baseElementsFor(from.BaseElements))
+	toTiers := elementTiers(toByName, baseElementsFor(to.BaseElements))
+
+	for _, name := range sortedKeys(toByName) {
+		if _, ok := fromByName[name]; !ok {
+			diff.AddedElements = append(diff.AddedElements, name)
+		}
+	}
+	for _, name := range sortedKeys(fromByName) {
+		if _, ok := toByName[name]; !ok {
+			diff.RemovedElements = append(diff.RemovedElements, name)
+		}
+	}
+
+	// Recipes of added and removed elements are listed too
+	names := sortedKeys(fromByName)
+	for _, name := range sortedKeys(toByName) {
+		if _, ok := fromByName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fromEl, inFrom := fromByName[name]
+		toEl, inTo := toByName[name]
+
+		fromRecipes := recipeKeys(fromEl)
+		toRecipes := recipeKeys(toEl)
+		for _, key := range sortedKeys(toRecipes) {
+			if !fromRecipes[key] {
+				diff.AddedRecipes = append(diff.AddedRecipes, RecipeChange{Element: name, Ingredients: strings.Split(key, "\x00")})
+			}
+		}
+		for _, key := range sortedKeys(fromRecipes) {
+			if !toRecipes[key] {
+				diff.RemovedRecipes = append(diff.RemovedRecipes, RecipeChange{Element: name, Ingredients: strings.Split(key, "\x00")})
+			}
+		}
+
+		if !inFrom || !inTo {
+			continue
+		}
+
+		// A new icon often keeps the path of the old one
+		change := ImageChange{Element: name, From: fromEl.ImagePath, To: toEl.ImagePath}
+		if fromHash, toHash := imageHash(from, fromEl), imageHash(to, toEl); fromHash != "" && toHash != "" {
+			change.FromHash, change.ToHash = fromHash, toHash
+		}
+		if change.From != change.To || change.FromHash != change.ToHash {
+			diff.ImageChanges = append(diff.ImageChanges, change)
+		}
+
+		fromTier, toTier := fromTiers[name], toTiers[name]
+		if fromTier != toTier {
+			diff.TierChanges = append(diff.TierChanges, TierChange{Element: name, From: fromTier, To: toTier})
+		}
+		if (fromTier == -1) != (toTier == -1) {
+			diff.ReachabilityChanges = append(diff.ReachabilityChanges, ReachabilityChange{
+				Element:       name,
+				FromReachable: fromTier != -1,
+				ToReachable:   toTier != -1,
+			})
+		}
+	}
+
+	return diff
+}
+
+// imageHash is the SHA-256 of the image of el, or "" when dataset has none.
+func imageHash(dataset *Dataset, el *Element) string {
+	if el.ImageHash != "" {
+		return el.ImageHash
+	}
+	return dataset.ImageChecksums[el.ImagePath]
+}
+
+func elementsByName(elements []Element) map[string]*Element {
+	byName := make(map[string]*Element, len(elements))
+	for i := range elements {
+		byName[elements[i].Name] = &elements[i]
+	}
+	return byName
+}
+
+// recipeKeys returns the 2-ingredient recipes of el, ingredients sorted so
+// A + B and B + A are the same recipe.
+func recipeKeys(el *Element) map[string]bool {
+	keys := map[string]bool{}
+	if el == nil {
+		return keys
+	}
+	for _, recipe := range el.Recipes {
+		if len(recipe) != 2 {
+			continue
+		}
+		ingredients := slices.Clone(recipe)
+		slices.Sort(ingredients)
+		keys[strings.Join(ingredients, "\x00")] = true
+	}
+	return keys
+}
+
+// elementTiers computes tiers over a plain element list, like
+// GraphView.computeTiers does for the loaded graph. Recipes are filtered like
+// when the dataset is loaded, so an element whose recipes are all invalid is
+// a base element here too.
+func elementTiers(byName map[string]*Element, base []string) map[string]int {
+	isElement := func(name string) bool {
+		_, ok := byName[name]
+		return ok
+	}
+	recipes := make(map[string][][]string, len(byName))
+	tiers := make(map[string]int, len(byName))
+	for name, el := range byName {
+		recipes[name] = validRecipes(el.Recipes, isElement)
+		tiers[name] = -1
+		if len(recipes[name]) == 0 || slices.Contains(base, name) {
+			tiers[name] = 0
+		}
+	}
+
+	for curTier := 0; ; curTier++ {
+		reached := map[string]bool{}
+		for name, tier := range tiers {
+			if tier != -1 {
+				reached[name] = true
+			}
+		}
+
+		progressed := false
+		for name := range byName {
+			if tiers[name] != -1 {
+				continue
+			}
+			for _, recipe := range recipes[name] {
+				if reached[recipe[0]] && reached[recipe[1]] {
+					tiers[name] = curTier + 1
+					progressed = true
+					break
+				}
+			}
+		}
+		if !progressed {
+			return tiers
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,21 +11,54 @@ import (
 
 // Fungsi utama algoritma DFS
 func DFSFindTrees(
+	ctx context.Context,
 	rootRecipeTree *RecipeTreeNode,
 	targetGraphNode *ElementsGraphNode,
 	maxTreeCount int,
 	signalTreeChange func(*RecipeTreeNode, int, int32),
 	globalStartTime time.Time,
 	globalNodeCounter *int32,
-	delayMs int,
+	view *GraphView,
+) ([]*RecipeTreeNode, error) {
+	// Tanpa filter tier ruang pencarian sangat besar, sehingga subtree yang sudah ditemukan disimpan
+	var memo *dfsMemo
+	if view.RecipePolicy != RecipePolicyStrictTier {
+		memo = newDFSMemo()
+	}
+	return dfsFindTrees(ctx, targetGraphNode, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, view, nil, memo)
+}
+
+// DFS rekursif dengan path berisi elemen-elemen yang sedang dibentuk di atas node ini.
+// Resep yang memakai elemen pada path membentuk siklus sehingga dilewati,
+// agar pencarian tetap berhenti walaupun view tidak memfilter resep berdasarkan tier
+func dfsFindTrees(
+	ctx context.Context,
+	targetGraphNode *ElementsGraphNode,
+	maxTreeCount int,
+	signalTreeChange func(*RecipeTreeNode, int, int32),
+	globalStartTime time.Time,
+	globalNodeCounter *int32,
+	view *GraphView,
+	path []string,
+	memo *dfsMemo,
 ) ([]*RecipeTreeNode, error) {
 	// Validasi awal jika node target tidak tersedia
 	if targetGraphNode == nil {
 		return nil, fmt.Errorf("targetGraphNode is nil")
 	}
 
-	// Jika node adalah base element atau tidak memiliki resep, maka return node sederhana
-	if len(targetGraphNode.RecipesToMakeThisElement) == 0 || IsBaseElement(targetGraphNode.Name) {
+	// Hentikan pencarian jika dibatalkan (misalnya server sedang shutdown)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Cabang yang lebih dalam dari batas kedalaman dipangkas
+	if tooDeep(ctx, len(path)) {
+		return nil, fmt.Errorf("%s is deeper than the depth limit", targetGraphNode.Name)
+	}
+
+	// Jika node adalah base element pada view, maka return node sederhana
+	if view.IsBaseElement(targetGraphNode.Name) {
 		node := &RecipeTreeNode{
 			Name:      targetGraphNode.Name,
 			ImagePath: GetImagePath(targetGraphNode.ImagePath),
@@ -31,6 +66,16 @@ func DFSFindTrees(
 		return []*RecipeTreeNode{node}, nil
 	}
 
+	// Pakai hasil sebelumnya untuk elemen ini jika masih valid pada path sekarang
+	if memo != nil {
+		if trees, ok := memo.lookup(targetGraphNode.Name, path); ok {
+			if len(trees) == 0 {
+				return nil, fmt.Errorf("no valid trees found for %s", targetGraphNode.Name)
+			}
+			return trees, nil
+		}
+	}
+
 	var (
 		result []*RecipeTreeNode // Menyimpan hasil pohon recipe yang ditemukan
 		mu     sync.Mutex        // Mutex untuk menghindari race condition
@@ -40,28 +85,40 @@ func DFSFindTrees(
 
 	treeChan := make(chan *RecipeTreeNode, maxTreeCount) // Channel untuk menyimpan hasil tree secara concurrent
 
+	// Path baru untuk anak-anak node ini (disalin agar aman dipakai antar goroutine)
+	childPath := append(slices.Clone(path), targetGraphNode.Name)
+
 	// Iterasi DFS untuk setiap resep yang memungkinkan dalam menghasilkan node target
-	for _, recipe := range targetGraphNode.RecipesToMakeThisElement {
+	// Resep yang belum dicoba termasuk frontier
+	recipes := view.RecipesFor(targetGraphNode)
+	untried := len(recipes)
+	addFrontier(ctx, untried)
+	defer func() { addFrontier(ctx, -untried) }()
+
+	for _, recipe := range recipes {
+		untried--
+		addFrontier(ctx, -1)
+
+		// Lewati resep yang memakai elemen pada path (siklus)
+		if slices.Contains(childPath, recipe.ElementOne.Name) || slices.Contains(childPath, recipe.ElementTwo.Name) {
+			continue
+		}
+
 		wg.Add(1)
-		go func(r *Recipe) {
+		searchRecipe := func(r *Recipe) {
 			defer wg.Done()
 
-			// Konfigurasi delay untuk update ExploringTree pada FE Visualization
-			if delayMs > 0 {
-				time.Sleep(time.Duration(delayMs) * time.Millisecond)
-			}
-
 			// Tambah hitungan node yang dieksplorasi
 			// Aman untuk goroutine
-			atomic.AddInt32(globalNodeCounter, 1)
+			exploreNode(ctx, globalNodeCounter)
 
 			// Recurssion DFS ke elemen kiri dan kanan dari resep
-			leftTrees, err1 := DFSFindTrees(nil, r.ElementOne, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, delayMs)
+			leftTrees, err1 := dfsFindTrees(ctx, r.ElementOne, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, view, childPath, memo)
 			if err1 != nil {
 				return
 			}
 
-			rightTrees, err2 := DFSFindTrees(nil, r.ElementTwo, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, delayMs)
+			rightTrees, err2 := dfsFindTrees(ctx, r.ElementTwo, maxTreeCount, signalTreeChange, globalStartTime, globalNodeCounter, view, childPath, memo)
 			if err2 != nil {
 				return
 			}
@@ -109,9 +166,20 @@ func DFSFindTrees(
 					treeChan <- root
 					count++
 					mu.Unlock()
+					if len(path) == 0 {
+						foundTree(ctx)
+					}
 				}
 			}
-		}(recipe)
+		}
+
+		// Resep pada level teratas dicari paralel, sedangkan level di bawahnya
+		// dicari berurutan agar pencarian berhenti begitu pohon sudah cukup
+		if len(path) == 0 {
+			go searchRecipe(recipe)
+		} else {
+			searchRecipe(recipe)
+		}
 
 		// Jika sudah cukup banyak pohon ditemukan
 		// Maka hentikan break dari loop luar
@@ -137,6 +205,11 @@ func DFSFindTrees(
 		}
 	}
 
+	// Hasil pencarian yang dibatalkan belum lengkap sehingga tidak disimpan
+	if memo != nil && ctx.Err() == nil {
+		memo.store(targetGraphNode.Name, path, result)
+	}
+
 	// Jika tidak ada tree valid ditemukan, maka return error
 	if len(result) == 0 {
 		return nil, fmt.Errorf("no valid trees found for %s", targetGraphNode.Name)
@@ -144,3 +217,73 @@ func DFSFindTrees(
 
 	return result, nil
 }
+
+// Cache hasil DFS per elemen dalam satu pencarian
+type dfsMemo struct {
+	mu     sync.Mutex
+	trees  map[string][]*RecipeTreeNode
+	failed map[string][][]string // Path-path tempat elemen gagal dibentuk
+}
+
+func newDFSMemo() *dfsMemo {
+	return &dfsMemo{
+		trees:  make(map[string][]*RecipeTreeNode),
+		failed: make(map[string][][]string),
+	}
+}
+
+// Tree yang tersimpan hanya valid jika tidak memuat elemen pada path, dan elemen
+// yang gagal pada suatu path juga pasti gagal pada path yang memuat path tersebut
+func (m *dfsMemo) lookup(name string, path []string) ([]*RecipeTreeNode, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var valid []*RecipeTreeNode
+	for _, tree := range m.trees[name] {
+		if !treeContainsAny(tree, path) {
+			valid = append(valid, tree)
+		}
+	}
+	if len(valid) > 0 {
+		return valid, true
+	}
+
+	for _, failedPath := range m.failed[name] {
+		if isSubset(failedPath, path) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+func (m *dfsMemo) store(name string, path []string, trees []*RecipeTreeNode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(trees) == 0 {
+		m.failed[name] = append(m.failed[name], path)
+		return
+	}
+	if len(trees) > len(m.trees[name]) {
+		m.trees[name] = trees
+	}
+}
+
+func treeContainsAny(tree *RecipeTreeNode, names []string) bool {
+	if tree == nil {
+		return false
+	}
+	if slices.Contains(names, tree.Name) {
+		return true
+	}
+	return treeContainsAny(tree.Element1, names) || treeContainsAny(tree.Element2, names)
+}
+
+func isSubset(subset []string, set []string) bool {
+	for _, name := range subset {
+		if !slices.Contains(set, name) {
+			return false
+		}
+	}
+	return true
+}
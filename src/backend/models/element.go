@@ -1,7 +1,6 @@
 package models
 
 import (
-	"encoding/json"
 	"os"
 )
 
@@ -9,21 +8,52 @@ type Element struct {
 	Name      string     `json:"name"`
 	Recipes   [][]string `json:"recipes"`
 	ImagePath string     `json:"image_path"`
+	ElementWikiInfo
+	ElementImageInfo
 }
 
-func LoadElementsFromJSON(filePath string) ([]Element, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// ElementImageInfo describes the processed icon of an element. Paths are in
+// the same form as ImagePath, asset keys served under /assets/.
+type ElementImageInfo struct {
+	ThumbPath   string `json:"thumb_path,omitempty"`
+	WebPPath    string `json:"webp_path,omitempty"`
+	ImageWidth  int    `json:"image_width,omitempty"`
+	ImageHeight int    `json:"image_height,omitempty"`
+	ImageHash   string `json:"image_hash,omitempty"` // SHA-256 of the PNG file
+}
+
+// ElementWikiInfo is read from the element's own wiki page by the scraper's
+// deep scrape (-deep). Datasets scraped without it leave every field empty.
+type ElementWikiInfo struct {
+	Description string `json:"description,omitempty"`
+	UsedIn      int    `json:"used_in,omitempty"`  // Number of recipes the element is an ingredient of
+	Pack        string `json:"pack,omitempty"`     // "Base game" or "Myths and Monsters"
+	IsFinal     bool   `json:"is_final,omitempty"` // Cannot be combined into anything else
+}
+
+// DataPath is the scraped dataset, overridable with DATA_PATH.
+func DataPath() string {
+	if path := os.Getenv("DATA_PATH"); path != "" {
+		return path
 	}
-	defer file.Close()
+	return "./data/elements.json"
+}
+
+// CustomDataPath is the copy of the dataset that recipe edits are saved to,
+// overridable with CUSTOM_DATA_PATH. When it exists it is loaded instead of
+// DataPath.
+func CustomDataPath() string {
+	if path := os.Getenv("CUSTOM_DATA_PATH"); path != "" {
+		return path
+	}
+	return "./data/elements.custom.json"
+}
 
-	decoder := json.NewDecoder(file)
-	var elements []Element
-	err = decoder.Decode(&elements)
+// LoadElementsFromJSON returns only the elements of the dataset at filePath.
+func LoadElementsFromJSON(filePath string) ([]Element, error) {
+	dataset, err := LoadDatasetFromJSON(filePath)
 	if err != nil {
 		return nil, err
 	}
-
-	return elements, nil
+	return dataset.Elements, nil
 }
@@ -1,18 +1,23 @@
 package models
 
 import (
-	"os"
-	"strings"
+	"sync"
 )
 
 type ElementsGraphNode struct {
-	Name                      string    	  `json:"name"`
-	ImagePath                 string    	  `json:"image_path"`
-	RecipesToMakeThisElement  []*Recipe 	  `json:"recipes_to_make_this_element"`
-	RecipesToMakeOtherElement []*Recipe 	  `json:"recipes_to_make_other_element"`
-	Tier                      int       	  `json:"tier"`
-	IsVisited                 bool      	  `json:"is_visited"`
-	MadeFrom				  map[string]bool `json:"made_from"`
+	Name                      string          `json:"name"`
+	ImagePath                 string          `json:"image_path"`
+	RecipesToMakeThisElement  []*Recipe       `json:"recipes_to_make_this_element"`
+	RecipesToMakeOtherElement []*Recipe       `json:"recipes_to_make_other_element"`
+	Tier                      int             `json:"tier"`
+	IsVisited                 bool            `json:"is_visited"`
+	MadeFrom                  map[string]bool `json:"made_from"`
+
+	// Every recipe from the dataset, before any view filtered it by tier
+	AllRecipesToMakeThisElement []*Recipe `json:"-"`
+
+	Wiki  ElementWikiInfo  `json:"wiki"`
+	Image ElementImageInfo `json:"image"`
 }
 
 type Recipe struct {
@@ -30,9 +35,19 @@ var ElementsGraph = &ElementsGraphNode{
 
 var nameToNode = make(map[string]*ElementsGraphNode)
 
+// graphMu guards nameToNode, its nodes and the default view. Readers hold
+// the read lock while recipe edits hold the write lock. Searches only hold it
+// to take their view and run on the view's snapshot of the graph.
+var graphMu sync.RWMutex
+
+// GetElementsGraphNodeByName also finds elements by alias and by any
+// capitalization of their name.
 func GetElementsGraphNodeByName(name string) (*ElementsGraphNode, bool) {
-	node, exists := nameToNode[name]
-	return node, exists
+	canonical, ok := resolveElementName(name)
+	if !ok {
+		return nil, false
+	}
+	return nameToNode[canonical], true
 }
 
 func (node *ElementsGraphNode) IsThisMadeFrom(element string) bool {
@@ -49,6 +64,12 @@ type ElementsGraphNodeDTO struct {
 	RecipesToMakeOtherElement []RecipeDTO `json:"recipes_to_make_other_element"`
 	IsVisited                 bool        `json:"is_visited"`
 	Tier                      int         `json:"tier"`
+	ThumbPath                 string      `json:"thumb_path,omitempty"`
+	WebPPath                  string      `json:"webp_path,omitempty"`
+	ImageWidth                int         `json:"image_width,omitempty"`
+	ImageHeight               int         `json:"image_height,omitempty"`
+	Sprite                    *SpriteRef  `json:"sprite,omitempty"`
+	ElementWikiInfo
 }
 
 type RecipeDTO struct {
@@ -57,16 +78,26 @@ type RecipeDTO struct {
 	TargetElementName string `json:"target_element_name"`
 }
 
-func GetJSONDTONodes() []ElementsGraphNodeDTO {
+// GetJSONDTONodes lists every element with asset URLs under baseURL.
+func GetJSONDTONodes(baseURL string) []ElementsGraphNodeDTO {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+
 	nameToNodeList := make([]ElementsGraphNodeDTO, 0)
 	for _, node := range nameToNode {
 		dto := ElementsGraphNodeDTO{
 			Name:                      node.Name,
-			ImagePath:                 GetImagePath(node.ImagePath),
+			ImagePath:                 AssetURL(baseURL, node.ImagePath),
 			RecipesToMakeThisElement:  make([]RecipeDTO, len(node.RecipesToMakeThisElement)),
 			RecipesToMakeOtherElement: make([]RecipeDTO, len(node.RecipesToMakeOtherElement)),
 			IsVisited:                 node.IsVisited,
 			Tier:                      node.Tier,
+			ThumbPath:                 AssetURL(baseURL, node.Image.ThumbPath),
+			WebPPath:                  AssetURL(baseURL, node.Image.WebPPath),
+			ImageWidth:                node.Image.ImageWidth,
+			ImageHeight:               node.Image.ImageHeight,
+			Sprite:                    spriteFor(node.ImagePath),
+			ElementWikiInfo:           node.Wiki,
 		}
 
 		for i, recipe := range node.RecipesToMakeThisElement {
@@ -90,53 +121,50 @@ func GetJSONDTONodes() []ElementsGraphNodeDTO {
 	return nameToNodeList
 }
 
-func GetImagePath(path string) string {
-	if path == "" {
-		return ""
-	}
-	path = strings.TrimPrefix(path, "../backend/")
-	path = strings.TrimPrefix(path, "/")
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "https://nyo.kirisame.jp.net/"
-	}
-	if !strings.HasSuffix(baseURL, "/") {
-		baseURL += "/"
-	}
-	// Concatenate the base URL with the normalized path
-	return baseURL + path
-}
+func GetElementsFromNameToNodeDTO(view *GraphView, baseURL string) []*ElementsGraphNodeDTO {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
 
-func GetElementsFromNameToNodeDTO() []*ElementsGraphNodeDTO {
 	// change recipe to string from nameToNode
 	nameToNodeList := make([]*ElementsGraphNodeDTO, 0)
 	for _, node := range nameToNode {
-		dto := &ElementsGraphNodeDTO{
-			Name:                      node.Name,
-			ImagePath:                 GetImagePath(node.ImagePath),
-			RecipesToMakeThisElement:  make([]RecipeDTO, len(node.RecipesToMakeThisElement)),
-			RecipesToMakeOtherElement: make([]RecipeDTO, len(node.RecipesToMakeOtherElement)),
-			IsVisited:                 node.IsVisited,
-			Tier:                      node.Tier,
-		}
+		nameToNodeList = append(nameToNodeList, toElementDTO(node, view, baseURL))
+	}
+	return nameToNodeList
+}
 
-		for i, recipe := range node.RecipesToMakeThisElement {
-			dto.RecipesToMakeThisElement[i] = RecipeDTO{
-				ElementOneName:    recipe.ElementOne.Name,
-				ElementTwoName:    recipe.ElementTwo.Name,
-				TargetElementName: recipe.TargetElementName,
-			}
-		}
+func toElementDTO(node *ElementsGraphNode, view *GraphView, baseURL string) *ElementsGraphNodeDTO {
+	recipes := view.RecipesFor(node)
+	dto := &ElementsGraphNodeDTO{
+		Name:                      node.Name,
+		ImagePath:                 AssetURL(baseURL, node.ImagePath),
+		RecipesToMakeThisElement:  make([]RecipeDTO, len(recipes)),
+		RecipesToMakeOtherElement: make([]RecipeDTO, len(node.RecipesToMakeOtherElement)),
+		IsVisited:                 node.IsVisited,
+		Tier:                      view.Tier(node.Name),
+		ThumbPath:                 AssetURL(baseURL, node.Image.ThumbPath),
+		WebPPath:                  AssetURL(baseURL, node.Image.WebPPath),
+		ImageWidth:                node.Image.ImageWidth,
+		ImageHeight:               node.Image.ImageHeight,
+		Sprite:                    spriteFor(node.ImagePath),
+		ElementWikiInfo:           node.Wiki,
+	}
 
-		for i, recipe := range node.RecipesToMakeOtherElement {
-			dto.RecipesToMakeOtherElement[i] = RecipeDTO{
-				ElementOneName:    recipe.ElementOne.Name,
-				ElementTwoName:    recipe.ElementTwo.Name,
-				TargetElementName: recipe.TargetElementName,
-			}
+	for i, recipe := range recipes {
+		dto.RecipesToMakeThisElement[i] = RecipeDTO{
+			ElementOneName:    recipe.ElementOne.Name,
+			ElementTwoName:    recipe.ElementTwo.Name,
+			TargetElementName: recipe.TargetElementName,
 		}
+	}
 
-		nameToNodeList = append(nameToNodeList, dto)
+	for i, recipe := range node.RecipesToMakeOtherElement {
+		dto.RecipesToMakeOtherElement[i] = RecipeDTO{
+			ElementOneName:    recipe.ElementOne.Name,
+			ElementTwoName:    recipe.ElementTwo.Name,
+			TargetElementName: recipe.TargetElementName,
+		}
 	}
-	return nameToNodeList
+
+	return dto
 }
@@ -0,0 +1,450 @@
+package models
+
+import (
+	"ccp/backend/metrics"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrElementNotFound = errors.New("element not found")
+	ErrElementExists   = errors.New("element already exists")
+	ErrElementInUse    = errors.New("element is still used")
+	ErrRecipeNotFound  = errors.New("recipe not found")
+	ErrRecipeExists    = errors.New("recipe already exists")
+	ErrInvalidRecipe   = errors.New("invalid recipe")
+)
+
+// AddElement adds a new element with its recipes, updates the graph and saves
+// it to CustomDataPath. The returned element has asset URLs under baseURL.
+func AddElement(name string, imagePath string, recipes [][]string, baseURL string) (*ElementsGraphNodeDTO, error) {
+	graphMu.Lock()
+	defer graphMu.Unlock()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidRecipe)
+	}
+	// Names differing only in case would be ambiguous to look up
+	if existing, ok := resolveElementName(name); ok {
+		return nil, fmt.Errorf("%w: %s", ErrElementExists, existing)
+	}
+	for i, recipe := range recipes {
+		if err := validateRecipe(name, recipe); err != nil {
+			return nil, err
+		}
+		recipes[i] = canonicalRecipe(name, recipe)
+	}
+
+	node := &ElementsGraphNode{
+		Name:                        name,
+		ImagePath:                   AssetKey(imagePath),
+		RecipesToMakeThisElement:    []*Recipe{},
+		RecipesToMakeOtherElement:   []*Recipe{},
+		AllRecipesToMakeThisElement: []*Recipe{},
+		Tier:                        -1,
+		MadeFrom:                    make(map[string]bool),
+	}
+	ingredients := []*ElementsGraphNode{}
+	for _, recipe := range recipes {
+		ingredients = append(ingredients, nameToNode[recipe[0]], nameToNode[recipe[1]])
+	}
+	restoreRecipes := keepRecipes(ingredients...)
+	order := elementOrder
+	undo := func() {
+		restoreRecipes()
+		delete(nameToNode, name)
+		elementOrder = order
+	}
+
+	nameToNode[name] = node
+	elementOrder = append(elementOrder, name)
+
+	for _, recipe := range recipes {
+		linkRecipe(node, nameToNode[recipe[0]], nameToNode[recipe[1]])
+	}
+
+	if err := applyGraphChange(node, undo); err != nil {
+		return nil, err
+	}
+	return toElementDTO(node, defaultView, baseURL), nil
+}
+
+// RemoveElement removes an element that no other element is made from.
+func RemoveElement(name string) error {
+	graphMu.Lock()
+	defer graphMu.Unlock()
+
+	node, ok := GetElementsGraphNodeByName(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrElementNotFound, name)
+	}
+	name = node.Name
+	if len(node.RecipesToMakeOtherElement) > 0 {
+		return fmt.Errorf("%w: %s is an ingredient in %d recipes", ErrElementInUse, name, len(node.RecipesToMakeOtherElement))
+	}
+
+	restoreRecipes := keepRecipes(append([]*ElementsGraphNode{node}, recipeIngredients(node.AllRecipesToMakeThisElement)...)...)
+	order := elementOrder
+	undo := func() {
+		restoreRecipes()
+		nameToNode[name] = node
+		elementOrder = order
+	}
+
+	for _, recipe := range slices.Clone(node.AllRecipesToMakeThisElement) {
+		unlinkRecipe(recipe)
+	}
+	delete(nameToNode, name)
+	elementOrder = slices.DeleteFunc(slices.Clone(elementOrder), func(n string) bool { return n == name })
+
+	return applyGraphChange(node, undo)
+}
+
+// AddRecipe adds the recipe one + two to the element name.
+func AddRecipe(name string, one string, two string, baseURL string) (*ElementsGraphNodeDTO, error) {
+	graphMu.Lock()
+	defer graphMu.Unlock()
+
+	node, ok := GetElementsGraphNodeByName(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrElementNotFound, name)
+	}
+	name = node.Name
+	if err := validateRecipe(name, []string{one, two}); err != nil {
+		return nil, err
+	}
+	one, two = canonicalName(one), canonicalName(two)
+	undo := keepRecipes(node, nameToNode[one], nameToNode[two])
+	if !linkRecipe(node, nameToNode[one], nameToNode[two]) {
+		return nil, fmt.Errorf("%w: %s + %s => %s", ErrRecipeExists, one, two, name)
+	}
+
+	if err := applyGraphChange(node, undo); err != nil {
+		return nil, err
+	}
+	return toElementDTO(node, defaultView, baseURL), nil
+}
+
+// RemoveRecipe removes the recipe one + two from the element name.
+func RemoveRecipe(name string, one string, two string, baseURL string) (*ElementsGraphNodeDTO, error) {
+	graphMu.Lock()
+	defer graphMu.Unlock()
+
+	node, ok := GetElementsGraphNodeByName(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrElementNotFound, name)
+	}
+	name, one, two = node.Name, canonicalName(one), canonicalName(two)
+
+	index := slices.IndexFunc(node.AllRecipesToMakeThisElement, func(r *Recipe) bool {
+		return (r.ElementOne.Name == one && safeName(r.ElementTwo) == two) ||
+			(r.ElementOne.Name == two && safeName(r.ElementTwo) == one)
+	})
+	if index == -1 {
+		return nil, fmt.Errorf("%w: %s + %s => %s", ErrRecipeNotFound, one, two, name)
+	}
+	recipe := node.AllRecipesToMakeThisElement[index]
+	undo := keepRecipes(append([]*ElementsGraphNode{node}, recipeIngredients([]*Recipe{recipe})...)...)
+	unlinkRecipe(recipe)
+
+	if err := applyGraphChange(node, undo); err != nil {
+		return nil, err
+	}
+	return toElementDTO(node, defaultView, baseURL), nil
+}
+
+func validateRecipe(name string, recipe []string) error {
+	if len(recipe) != 2 {
+		return fmt.Errorf("%w: a recipe needs exactly 2 ingredients, got %d", ErrInvalidRecipe, len(recipe))
+	}
+	for _, ingredient := range recipe {
+		if ingredient == name {
+			continue
+		}
+		if _, ok := resolveElementName(ingredient); !ok {
+			return fmt.Errorf("%w: ingredient %s", ErrElementNotFound, ingredient)
+		}
+	}
+	return nil
+}
+
+// canonicalName is the element name that name spells or is an alias of, or
+// name itself when there is none.
+func canonicalName(name string) string {
+	if canonical, ok := resolveElementName(name); ok {
+		return canonical
+	}
+	return name
+}
+
+// canonicalRecipe spells the ingredients of a recipe of name like the
+// elements they refer to.
+func canonicalRecipe(name string, recipe []string) []string {
+	canonical := make([]string, len(recipe))
+	for i, ingredient := range recipe {
+		if ingredient == name {
+			canonical[i] = name
+		} else {
+			canonical[i] = canonicalName(ingredient)
+		}
+	}
+	return canonical
+}
+
+// linkRecipe adds one + two => result to all three nodes, reporting false when
+// the recipe already exists.
+func linkRecipe(result *ElementsGraphNode, one *ElementsGraphNode, two *ElementsGraphNode) bool {
+	recipe := &Recipe{
+		ElementOne:        one,
+		ElementTwo:        two,
+		TargetElementName: result.Name,
+	}
+	if containsRecipe(result.AllRecipesToMakeThisElement, recipe) {
+		return false
+	}
+
+	result.AllRecipesToMakeThisElement = append(result.AllRecipesToMakeThisElement, recipe)
+	one.RecipesToMakeOtherElement = append(one.RecipesToMakeOtherElement, recipe)
+	if one != two {
+		two.RecipesToMakeOtherElement = append(two.RecipesToMakeOtherElement, recipe)
+	}
+	return true
+}
+
+// recipeIngredients returns the ingredients of recipes.
+func recipeIngredients(recipes []*Recipe) []*ElementsGraphNode {
+	ingredients := []*ElementsGraphNode{}
+	for _, recipe := range recipes {
+		ingredients = append(ingredients, recipe.ElementOne)
+		if recipe.ElementTwo != nil {
+			ingredients = append(ingredients, recipe.ElementTwo)
+		}
+	}
+	return ingredients
+}
+
+// keepRecipes returns a function that puts back the recipe lists nodes have
+// now, to undo an edit. linkRecipe and unlinkRecipe never change a list in
+// place, so keeping the lists is enough.
+func keepRecipes(nodes ...*ElementsGraphNode) func() {
+	type recipeLists struct {
+		toMake      []*Recipe
+		toMakeOther []*Recipe
+	}
+	kept := make(map[*ElementsGraphNode]recipeLists, len(nodes))
+	for _, node := range nodes {
+		if node != nil {
+			kept[node] = recipeLists{node.AllRecipesToMakeThisElement, node.RecipesToMakeOtherElement}
+		}
+	}
+	return func() {
+		for node, lists := range kept {
+			node.AllRecipesToMakeThisElement = lists.toMake
+			node.RecipesToMakeOtherElement = lists.toMakeOther
+		}
+	}
+}
+
+func unlinkRecipe(recipe *Recipe) {
+	isRecipe := func(r *Recipe) bool { return r == recipe }
+	if result, ok := nameToNode[recipe.TargetElementName]; ok {
+		result.AllRecipesToMakeThisElement = slices.DeleteFunc(slices.Clone(result.AllRecipesToMakeThisElement), isRecipe)
+	}
+	recipe.ElementOne.RecipesToMakeOtherElement = slices.DeleteFunc(slices.Clone(recipe.ElementOne.RecipesToMakeOtherElement), isRecipe)
+	if recipe.ElementTwo != nil {
+		recipe.ElementTwo.RecipesToMakeOtherElement = slices.DeleteFunc(slices.Clone(recipe.ElementTwo.RecipesToMakeOtherElement), isRecipe)
+	}
+}
+
+// applyGraphChange saves the graph after changed gained or lost recipes,
+// then brings the base elements, tiers, filtered recipes and MadeFrom up to
+// date. Only changed and the elements made from it are recomputed. When the
+// graph cannot be saved, undo reverts the edit and nothing else changes.
+func applyGraphChange(changed *ElementsGraphNode, undo func()) error {
+	if err := saveGraph(); err != nil {
+		undo()
+		return err
+	}
+
+	affected := upwardClosure(changed)
+
+	// Recipe-less elements are base elements, like when the dataset is loaded
+	if _, exists := nameToNode[changed.Name]; !exists {
+		baseElements = slices.DeleteFunc(slices.Clone(baseElements), func(n string) bool { return n == changed.Name })
+	} else if len(changed.AllRecipesToMakeThisElement) == 0 && !slices.Contains(baseElements, changed.Name) {
+		baseElements = append(slices.Clone(baseElements), changed.Name)
+	} else if len(changed.AllRecipesToMakeThisElement) > 0 && slices.Contains(baseElements, changed.Name) && !slices.Contains(configuredBaseElements(), changed.Name) {
+		baseElements = slices.DeleteFunc(slices.Clone(baseElements), func(n string) bool { return n == changed.Name })
+	}
+	ElementsGraph.RecipesToMakeOtherElement = make([]*Recipe, 0, len(baseElements))
+	for _, name := range baseElements {
+		ElementsGraph.RecipesToMakeOtherElement = append(ElementsGraph.RecipesToMakeOtherElement, &Recipe{
+			ElementOne: nameToNode[name],
+			ElementTwo: nil,
+		})
+	}
+
+	// Searches may still hold the old view, so the new one gets its own maps
+	view := &GraphView{
+		BaseElements: baseElements,
+		RecipePolicy: defaultView.RecipePolicy,
+		base:         make(map[string]bool, len(baseElements)),
+		tiers:        maps.Clone(defaultView.tiers),
+		recipes:      maps.Clone(defaultView.recipes),
+	}
+	for _, name := range baseElements {
+		view.base[name] = true
+	}
+	view.snapshotGraph()
+	delete(view.tiers, changed.Name)
+	delete(view.recipes, changed.Name)
+
+	view.updateTiers(affected)
+	view.updateRecipes(affected)
+	defaultView = view
+
+	for _, node := range affected {
+		node.Tier = view.Tier(node.Name)
+		node.RecipesToMakeThisElement = view.RecipesFor(node)
+	}
+	updateMadeFrom(affected)
+	buildNameIndex()
+	invalidateSearchCache()
+	metrics.GraphEdits.Inc()
+	updateGraphMetrics()
+	return nil
+}
+
+// upwardClosure returns node and every element that can be made from it,
+// ignoring elements no longer in the graph.
+func upwardClosure(node *ElementsGraphNode) []*ElementsGraphNode {
+	visited := map[string]bool{node.Name: true}
+	closure := []*ElementsGraphNode{}
+	if _, ok := nameToNode[node.Name]; ok {
+		closure = append(closure, node)
+	}
+
+	queue := []*ElementsGraphNode{node}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, recipe := range cur.RecipesToMakeOtherElement {
+			result, ok := nameToNode[recipe.TargetElementName]
+			if !ok || visited[result.Name] {
+				continue
+			}
+			visited[result.Name] = true
+			closure = append(closure, result)
+			queue = append(queue, result)
+		}
+	}
+	return closure
+}
+
+// updateTiers recomputes the tiers of nodes while every other tier stays as
+// it is. A tier is one more than the lowest tier any recipe can be made at.
+func (v *GraphView) updateTiers(nodes []*ElementsGraphNode) {
+	for _, node := range nodes {
+		if v.base[node.Name] {
+			v.tiers[node.Name] = 0
+		} else {
+			v.tiers[node.Name] = -1
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, node := range nodes {
+			if v.base[node.Name] {
+				continue
+			}
+			best := -1
+			for _, recipe := range node.AllRecipesToMakeThisElement {
+				recipeTier := v.recipeTier(recipe)
+				if recipeTier == math.MaxInt32 {
+					continue
+				}
+				if best == -1 || recipeTier+1 < best {
+					best = recipeTier + 1
+				}
+			}
+			if best != -1 && (v.tiers[node.Name] == -1 || best < v.tiers[node.Name]) {
+				v.tiers[node.Name] = best
+				changed = true
+			}
+		}
+	}
+}
+
+func (v *GraphView) updateRecipes(nodes []*ElementsGraphNode) {
+	for _, node := range nodes {
+		v.recipes[node.Name] = v.filterRecipesFor(node)
+	}
+}
+
+// updateMadeFrom rebuilds MadeFrom for nodes.
+func updateMadeFrom(nodes []*ElementsGraphNode) {
+	for _, node := range nodes {
+		node.MadeFrom = madeFrom(node)
+	}
+}
+
+// madeFrom returns every element below node in any of its recipes. In a
+// cycle that includes node itself.
+func madeFrom(node *ElementsGraphNode) map[string]bool {
+	made := make(map[string]bool)
+	stack := []*ElementsGraphNode{node}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, recipe := range cur.AllRecipesToMakeThisElement {
+			for _, ingredient := range []*ElementsGraphNode{recipe.ElementOne, recipe.ElementTwo} {
+				if ingredient != nil && !made[ingredient.Name] {
+					made[ingredient.Name] = true
+					stack = append(stack, ingredient)
+				}
+			}
+		}
+	}
+	return made
+}
+
+// saveGraph writes the current graph to CustomDataPath in dataset order,
+// keeping the metadata of the loaded dataset.
+func saveGraph() error {
+	dataset := *loadedDataset
+	dataset.Elements = make([]Element, 0, len(elementOrder))
+	for _, name := range elementOrder {
+		node := nameToNode[name]
+		element := Element{
+			Name:             node.Name,
+			Recipes:          make([][]string, 0, len(node.AllRecipesToMakeThisElement)),
+			ImagePath:        node.ImagePath,
+			ElementWikiInfo:  node.Wiki,
+			ElementImageInfo: node.Image,
+		}
+		for _, recipe := range node.AllRecipesToMakeThisElement {
+			element.Recipes = append(element.Recipes, []string{recipe.ElementOne.Name, safeName(recipe.ElementTwo)})
+		}
+		dataset.Elements = append(dataset.Elements, element)
+	}
+	dataset.BaseElements = slices.DeleteFunc(slices.Clone(dataset.BaseElements), func(name string) bool {
+		_, ok := nameToNode[name]
+		return !ok
+	})
+	dataset.Aliases = maps.Clone(dataset.Aliases)
+	maps.DeleteFunc(dataset.Aliases, func(alias string, name string) bool {
+		_, ok := nameToNode[name]
+		return !ok
+	})
+
+	if err := SaveDatasetToJSON(&dataset, CustomDataPath()); err != nil {
+		return fmt.Errorf("failed to save elements to %s: %w", CustomDataPath(), err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// useEditGraph loads a small dataset and saves edits to a temporary file.
+func useEditGraph(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	data := `[
+		{"name": "Air", "recipes": []}, {"name": "Earth", "recipes": []},
+		{"name": "Fire", "recipes": []}, {"name": "Water", "recipes": []},
+		{"name": "Steam", "recipes": [["Water", "Fire"]]},
+		{"name": "Lava", "recipes": [["Earth", "Fire"]]},
+		{"name": "Stone", "recipes": [["Lava", "Air"]]},
+		{"name": "Cloud", "recipes": [["Steam", "Air"], ["Stone", "Steam"]]}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "elements.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATA_PATH", filepath.Join(dir, "elements.json"))
+	t.Setenv("CUSTOM_DATA_PATH", filepath.Join(dir, "elements.custom.json"))
+	t.Setenv("ALIASES_PATH", filepath.Join(dir, "aliases.json"))
+	t.Setenv("BASE_ELEMENTS", "")
+	InitElementsGraph()
+}
+
+// graphState describes everything an edit keeps up to date, per element.
+func graphState() map[string]string {
+	recipeNames := func(recipes []*Recipe) []string {
+		names := []string{}
+		for _, recipe := range recipes {
+			names = append(names, recipe.ElementOne.Name+"+"+safeName(recipe.ElementTwo)+"="+recipe.TargetElementName)
+		}
+		slices.Sort(names)
+		return names
+	}
+	state := map[string]string{"base": fmt.Sprint(slices.Sorted(slices.Values(baseElements)))}
+	for name, node := range nameToNode {
+		state[name] = fmt.Sprint(
+			node.Tier, defaultView.Tier(name), defaultView.IsBaseElement(name),
+			recipeNames(node.AllRecipesToMakeThisElement),
+			recipeNames(node.RecipesToMakeThisElement),
+			recipeNames(defaultView.RecipesFor(node)),
+			recipeNames(node.RecipesToMakeOtherElement),
+			slices.Sorted(maps.Keys(node.MadeFrom)),
+		)
+	}
+	return state
+}
+
+// Every edit and the edit undoing it must leave the graph as it would be
+// built from scratch out of the saved dataset.
+func TestGraphEdits(t *testing.T) {
+	addElement := func(name string, recipes ...[]string) func() error {
+		return func() error {
+			_, err := AddElement(name, "", recipes, "")
+			return err
+		}
+	}
+	removeElement := func(name string) func() error {
+		return func() error { return RemoveElement(name) }
+	}
+	addRecipe := func(name string, one string, two string) func() error {
+		return func() error {
+			_, err := AddRecipe(name, one, two, "")
+			return err
+		}
+	}
+	removeRecipe := func(name string, one string, two string) func() error {
+		return func() error {
+			_, err := RemoveRecipe(name, one, two, "")
+			return err
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func() error
+		undo func() error
+	}{
+		{
+			name: "element on top",
+			edit: addElement("Geyser", []string{"Steam", "Earth"}),
+			undo: removeElement("Geyser"),
+		},
+		{
+			name: "element without recipes is a base element",
+			edit: addElement("Time"),
+			undo: removeElement("Time"),
+		},
+		{
+			name: "lower tier recipe",
+			edit: addRecipe("Cloud", "Water", "Air"),
+			undo: removeRecipe("Cloud", "Water", "Air"),
+		},
+		{
+			name: "recipe making a cycle",
+			edit: addRecipe("Lava", "Stone", "Fire"),
+			undo: removeRecipe("Lava", "Stone", "Fire"),
+		},
+		{
+			name: "only recipe removed",
+			edit: removeRecipe("Steam", "Water", "Fire"),
+			undo: addRecipe("Steam", "Water", "Fire"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useEditGraph(t)
+			for _, step := range []func() error{tt.edit, tt.undo} {
+				if err := step(); err != nil {
+					t.Fatal(err)
+				}
+				edited := graphState()
+				InitElementsGraph()
+				if rebuilt := graphState(); !reflect.DeepEqual(edited, rebuilt) {
+					t.Fatalf("after the edit\n%v\nrebuilt from the saved dataset\n%v", edited, rebuilt)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphEditNotSaved(t *testing.T) {
+	useEditGraph(t)
+	before := graphState()
+
+	// The dataset cannot be written under a regular file
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CUSTOM_DATA_PATH", filepath.Join(blocker, "elements.custom.json"))
+
+	edits := map[string]func() error{
+		"add element":    func() error { _, err := AddElement("Geyser", "", [][]string{{"Steam", "Earth"}}, ""); return err },
+		"remove element": func() error { return RemoveElement("Cloud") },
+		"add recipe":     func() error { _, err := AddRecipe("Lava", "Stone", "Fire", ""); return err },
+		"remove recipe":  func() error { _, err := RemoveRecipe("Cloud", "Steam", "Air", ""); return err },
+	}
+	for name, edit := range edits {
+		if err := edit(); err == nil {
+			t.Errorf("%s: saved under a file", name)
+		}
+		if after := graphState(); !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: graph changed by an edit that was not saved\n%v\nwant\n%v", name, after, before)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Recipe filtering policies a view can apply to the recipes of each element.
+const (
+	// Only recipes whose ingredients have a lower tier than the result
+	RecipePolicyStrictTier = "strict-tier"
+	// Every recipe of reachable elements that does not make an element out of
+	// something made from it, so no element is ever part of its own recipe tree
+	RecipePolicyAcyclicOnly = "acyclic-only"
+	// Every recipe from the dataset, cycles included
+	RecipePolicyNone = "none"
+)
+
+// GraphView is the elements graph as seen from one starting set of base
+// elements. Tiers and the recipes usable by the searches are recomputed for
+// that set, so the same graph can answer requests for different start sets.
+type GraphView struct {
+	BaseElements []string
+	RecipePolicy string
+
+	base    map[string]bool
+	tiers   map[string]int
+	recipes map[string][]*Recipe
+	// The elements and the recipes each one is used in when the view was
+	// made, so searches never read the graph while it is being edited
+	nodes map[string]*ElementsGraphNode
+	uses  map[string][]*Recipe
+}
+
+// NewGraphView builds a view that starts from exactly the given elements and
+// filters recipes with policy.
+func NewGraphView(base []string, policy string) (*GraphView, error) {
+	if len(base) == 0 {
+		return nil, fmt.Errorf("base elements must not be empty")
+	}
+	if !IsValidRecipePolicy(policy) {
+		return nil, fmt.Errorf("invalid recipe policy: %s", policy)
+	}
+
+	names := make([]string, 0, len(base))
+	for _, name := range base {
+		canonical, ok := resolveElementName(name)
+		if !ok {
+			return nil, fmt.Errorf("base element %s not found in elements graph", name)
+		}
+		name = canonical
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return newGraphView(names, policy), nil
+}
+
+// GetGraphView returns the view for base and policy. An empty base means the
+// default base elements and an empty policy means strict-tier.
+func GetGraphView(base []string, policy string) (*GraphView, error) {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+	return getGraphView(base, policy)
+}
+
+func getGraphView(base []string, policy string) (*GraphView, error) {
+	if defaultView == nil {
+		return nil, fmt.Errorf("elements graph is not initialized")
+	}
+	if policy == "" {
+		policy = RecipePolicyStrictTier
+	}
+	if len(base) == 0 {
+		if policy == defaultView.RecipePolicy {
+			return defaultView, nil
+		}
+		base = defaultView.BaseElements
+	}
+	return NewGraphView(base, policy)
+}
+
+func IsValidRecipePolicy(policy string) bool {
+	switch policy {
+	case RecipePolicyStrictTier, RecipePolicyAcyclicOnly, RecipePolicyNone:
+		return true
+	}
+	return false
+}
+
+func newGraphView(base []string, policy string) *GraphView {
+	view := &GraphView{
+		BaseElements: base,
+		RecipePolicy: policy,
+		base:         make(map[string]bool, len(base)),
+		tiers:        make(map[string]int, len(nameToNode)),
+		recipes:      make(map[string][]*Recipe, len(nameToNode)),
+	}
+	for _, name := range base {
+		view.base[name] = true
+	}
+
+	view.snapshotGraph()
+	view.computeTiers()
+	view.filterRecipes()
+	return view
+}
+
+// snapshotGraph keeps the elements of the graph and the recipes using each
+// of them. Edits replace recipe lists instead of changing them in place, so
+// keeping the lists is enough.
+func (v *GraphView) snapshotGraph() {
+	v.nodes = maps.Clone(nameToNode)
+	v.uses = make(map[string][]*Recipe, len(nameToNode))
+	for name, node := range nameToNode {
+		v.uses[name] = node.RecipesToMakeOtherElement
+	}
+}
+
+// computeTiers assigns tier 0 to the base elements and tier n+1 to every
+// element that has a recipe made only of elements of tier n or lower.
+// Elements that cannot be reached from the base set keep tier -1.
+func (v *GraphView) computeTiers() {
+	for name := range nameToNode {
+		if v.base[name] {
+			v.tiers[name] = 0
+		} else {
+			v.tiers[name] = -1
+		}
+	}
+
+	curTier := 0
+	for {
+		nodesWithInitializedTier := map[string]bool{}
+		for name, tier := range v.tiers {
+			if tier != -1 {
+				nodesWithInitializedTier[name] = true
+			}
+		}
+
+		progressed := false
+		for name, node := range nameToNode {
+			if v.tiers[name] != -1 {
+				continue
+			}
+			for _, recipe := range node.AllRecipesToMakeThisElement {
+				if nodesWithInitializedTier[recipe.ElementOne.Name] && (recipe.ElementTwo == nil || nodesWithInitializedTier[recipe.ElementTwo.Name]) {
+					v.tiers[name] = curTier + 1
+					progressed = true
+					break
+				}
+			}
+		}
+
+		// Stop once a round unlocks nothing new, the rest is unreachable
+		if !progressed {
+			break
+		}
+		curTier++
+	}
+}
+
+// filterRecipes keeps the recipes of every element allowed by the policy.
+func (v *GraphView) filterRecipes() {
+	for name, node := range nameToNode {
+		v.recipes[name] = v.filterRecipesFor(node)
+	}
+	if v.RecipePolicy == RecipePolicyAcyclicOnly {
+		v.dropCycles()
+	}
+}
+
+// dropCycles removes the recipes whose result is already among what one of
+// their ingredients is made from. Recipes are added back one at a time,
+// those that respect the tiers first and then from the lowest tier
+// ingredients up, so every strict-tier recipe is kept and the recipes of
+// each element keep their order.
+func (v *GraphView) dropCycles() {
+	type candidate struct {
+		result string
+		recipe *Recipe
+		strict bool
+		index  int
+	}
+	candidates := []candidate{}
+	for name, recipes := range v.recipes {
+		tier := v.tiers[name]
+		for i, recipe := range recipes {
+			strict := v.isLowerTier(recipe.ElementOne, tier) && v.isLowerTier(recipe.ElementTwo, tier)
+			candidates = append(candidates, candidate{result: name, recipe: recipe, strict: strict, index: i})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.strict != b.strict {
+			if a.strict {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(v.recipeTier(a.recipe), v.recipeTier(b.recipe)),
+			strings.Compare(a.result, b.result),
+			cmp.Compare(a.index, b.index),
+		)
+	})
+
+	// Elements are numbered so the reachability checks below need no maps
+	ids := make(map[string]int, len(v.recipes))
+	for name := range v.recipes {
+		ids[name] = len(ids)
+	}
+	madeFrom := make([][]int, len(ids)) // Ingredients of the kept recipes of each element
+	visited := make([]int, len(ids))
+	stamp := 0
+	// isMadeFrom reports whether target is below from in the kept recipes
+	isMadeFrom := func(from *ElementsGraphNode, target int) bool {
+		if from == nil {
+			return false
+		}
+		stamp++
+		stack := []int{ids[from.Name]}
+		visited[stack[0]] = stamp
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, ingredient := range madeFrom[cur] {
+				if ingredient == target {
+					return true
+				}
+				if visited[ingredient] != stamp {
+					visited[ingredient] = stamp
+					stack = append(stack, ingredient)
+				}
+			}
+		}
+		return false
+	}
+
+	kept := make(map[string][]*Recipe, len(v.recipes))
+	for name, recipes := range v.recipes {
+		kept[name] = make([]*Recipe, 0, len(recipes))
+	}
+	for _, c := range candidates {
+		result := ids[c.result]
+		if isMadeFrom(c.recipe.ElementOne, result) || isMadeFrom(c.recipe.ElementTwo, result) {
+			continue
+		}
+		kept[c.result] = append(kept[c.result], c.recipe)
+		for _, ingredient := range []*ElementsGraphNode{c.recipe.ElementOne, c.recipe.ElementTwo} {
+			if ingredient != nil {
+				madeFrom[result] = append(madeFrom[result], ids[ingredient.Name])
+			}
+		}
+	}
+	v.recipes = kept
+}
+
+func (v *GraphView) filterRecipesFor(node *ElementsGraphNode) []*Recipe {
+	tier := v.tiers[node.Name]
+	filtered := make([]*Recipe, 0, len(node.AllRecipesToMakeThisElement))
+	for _, recipe := range node.AllRecipesToMakeThisElement {
+		if v.RecipePolicy == RecipePolicyNone {
+			filtered = append(filtered, recipe)
+			continue
+		}
+		if v.RecipePolicy == RecipePolicyStrictTier {
+			if !v.isLowerTier(recipe.ElementOne, tier) || !v.isLowerTier(recipe.ElementTwo, tier) {
+				continue
+			}
+		} else if !v.isUsableIngredient(recipe.ElementOne, node.Name) || !v.isUsableIngredient(recipe.ElementTwo, node.Name) {
+			continue
+		}
+		filtered = append(filtered, recipe)
+	}
+
+	// Without the tier filter, try the recipes with the lowest tier ingredients first
+	if v.RecipePolicy != RecipePolicyStrictTier {
+		slices.SortStableFunc(filtered, func(a, b *Recipe) int {
+			return v.recipeTier(a) - v.recipeTier(b)
+		})
+	}
+	return filtered
+}
+
+// recipeTier is the highest tier among the ingredients of recipe, with
+// unreachable ingredients sorting last.
+func (v *GraphView) recipeTier(recipe *Recipe) int {
+	tier := v.Tier(recipe.ElementOne.Name)
+	if tier == -1 {
+		return math.MaxInt32
+	}
+	if recipe.ElementTwo != nil {
+		tierTwo := v.Tier(recipe.ElementTwo.Name)
+		if tierTwo == -1 {
+			return math.MaxInt32
+		}
+		tier = max(tier, tierTwo)
+	}
+	return tier
+}
+
+func (v *GraphView) isLowerTier(ingredient *ElementsGraphNode, tier int) bool {
+	if ingredient == nil {
+		return true
+	}
+	ingredientTier := v.tiers[ingredient.Name]
+	return ingredientTier != -1 && ingredientTier < tier
+}
+
+func (v *GraphView) isUsableIngredient(ingredient *ElementsGraphNode, result string) bool {
+	if ingredient == nil {
+		return true
+	}
+	return ingredient.Name != result && v.IsReachable(ingredient.Name) && v.IsReachable(result)
+}
+
+func (v *GraphView) IsBaseElement(name string) bool {
+	return v.base[name]
+}
+
+// Tier returns the tier of name in this view, or -1 when it is unreachable.
+func (v *GraphView) Tier(name string) int {
+	tier, ok := v.tiers[name]
+	if !ok {
+		return -1
+	}
+	return tier
+}
+
+func (v *GraphView) IsReachable(name string) bool {
+	return v.Tier(name) != -1
+}
+
+// Node returns the element called name as it was when the view was made.
+func (v *GraphView) Node(name string) (*ElementsGraphNode, bool) {
+	node, ok := v.nodes[name]
+	return node, ok
+}
+
+// RecipesUsing returns the recipes node is an ingredient of, whatever the
+// policy.
+func (v *GraphView) RecipesUsing(node *ElementsGraphNode) []*Recipe {
+	if node == nil {
+		return nil
+	}
+	return v.uses[node.Name]
+}
+
+// RecipesFor returns the recipes the searches may use to make node.
+func (v *GraphView) RecipesFor(node *ElementsGraphNode) []*Recipe {
+	if node == nil {
+		return nil
+	}
+	return v.recipes[node.Name]
+}
+
+// IsMadeFrom reports whether element appears anywhere below node when only
+// the recipes of this view are followed.
+func (v *GraphView) IsMadeFrom(node *ElementsGraphNode, element string) bool {
+	if node == nil {
+		return false
+	}
+
+	visited := map[string]bool{node.Name: true}
+	stack := []*ElementsGraphNode{node}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, recipe := range v.RecipesFor(cur) {
+			for _, ingredient := range []*ElementsGraphNode{recipe.ElementOne, recipe.ElementTwo} {
+				if ingredient == nil {
+					continue
+				}
+				if ingredient.Name == element {
+					return true
+				}
+				if !visited[ingredient.Name] {
+					visited[ingredient.Name] = true
+					stack = append(stack, ingredient)
+				}
+			}
+		}
+	}
+	return false
+}
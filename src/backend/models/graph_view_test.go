@@ -0,0 +1,224 @@
+package models
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setTestGraph replaces the elements graph with a small one that has
+// cycles, a recipe using its own result and an unreachable element:
+//
+//	Steam = Water + Fire | Cloud + Fire | Lava + Water
+//	Cloud = Steam + Air, Lava = Earth + Fire
+//	Stone = Lava + Air | Stone + Stone, Water = Cloud + Earth
+//	Mud = Water + Fire | Earth + Clay, Clay = Fire + Mud
+//	Brick = Air + Clay | Brick + Clay, Kiln = Fire + Brick
+//	Ghost = Ghost + Air
+func setTestGraph(t *testing.T) {
+	t.Helper()
+	recipes := map[string][][2]string{
+		"Air":   {},
+		"Earth": {},
+		"Fire":  {},
+		"Water": {{"Cloud", "Earth"}},
+		"Steam": {{"Water", "Fire"}, {"Cloud", "Fire"}, {"Lava", "Water"}},
+		"Cloud": {{"Steam", "Air"}},
+		"Lava":  {{"Earth", "Fire"}},
+		"Stone": {{"Lava", "Air"}, {"Stone", "Stone"}},
+		"Mud":   {{"Earth", "Clay"}, {"Water", "Fire"}},
+		"Clay":  {{"Fire", "Mud"}},
+		"Brick": {{"Brick", "Clay"}, {"Air", "Clay"}},
+		"Kiln":  {{"Fire", "Brick"}},
+		"Ghost": {{"Ghost", "Air"}},
+	}
+
+	previousNodes, previousView := nameToNode, defaultView
+	t.Cleanup(func() { nameToNode, defaultView = previousNodes, previousView })
+
+	nameToNode = map[string]*ElementsGraphNode{}
+	for name := range recipes {
+		nameToNode[name] = &ElementsGraphNode{Name: name, Tier: -1}
+	}
+	for _, name := range slices.Sorted(maps.Keys(recipes)) {
+		node := nameToNode[name]
+		for _, ingredients := range recipes[name] {
+			recipe := &Recipe{
+				ElementOne:        nameToNode[ingredients[0]],
+				ElementTwo:        nameToNode[ingredients[1]],
+				TargetElementName: name,
+			}
+			node.AllRecipesToMakeThisElement = append(node.AllRecipesToMakeThisElement, recipe)
+			recipe.ElementOne.RecipesToMakeOtherElement = append(recipe.ElementOne.RecipesToMakeOtherElement, recipe)
+			recipe.ElementTwo.RecipesToMakeOtherElement = append(recipe.ElementTwo.RecipesToMakeOtherElement, recipe)
+		}
+	}
+	defaultView = newGraphView(DefaultBaseElements, RecipePolicyStrictTier)
+}
+
+func TestNewGraphView(t *testing.T) {
+	setTestGraph(t)
+
+	tests := []struct {
+		name   string
+		base   []string
+		policy string
+		tiers  map[string]int // Unlisted elements are unreachable
+		err    string
+	}{
+		{
+			name:   "default base",
+			base:   []string{"Air", "Earth", "Fire", "Water"},
+			policy: RecipePolicyStrictTier,
+			tiers: map[string]int{
+				"Air": 0, "Earth": 0, "Fire": 0, "Water": 0,
+				"Steam": 1, "Lava": 1, "Mud": 1, "Cloud": 2, "Stone": 2, "Clay": 2, "Brick": 3, "Kiln": 4,
+			},
+		},
+		{
+			name:   "smaller base",
+			base:   []string{"Water", "Fire", "Water"},
+			policy: RecipePolicyNone,
+			tiers:  map[string]int{"Water": 0, "Fire": 0, "Steam": 1, "Mud": 1, "Clay": 2},
+		},
+		{
+			name:   "made elements as base",
+			base:   []string{"Steam", "Air", "Earth"},
+			policy: RecipePolicyAcyclicOnly,
+			tiers:  map[string]int{"Steam": 0, "Air": 0, "Earth": 0, "Cloud": 1, "Water": 2},
+		},
+		{name: "empty base", base: []string{}, policy: RecipePolicyNone, err: "base elements must not be empty"},
+		{name: "unknown element", base: []string{"Plasma"}, policy: RecipePolicyNone, err: "base element Plasma not found in elements graph"},
+		{name: "unknown policy", base: []string{"Air"}, policy: "loose", err: "invalid recipe policy: loose"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, err := NewGraphView(tt.base, tt.policy)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name := range nameToNode {
+				want, ok := tt.tiers[name]
+				if !ok {
+					want = -1
+				}
+				if tier := view.Tier(name); tier != want {
+					t.Errorf("tier of %s = %d, want %d", name, tier, want)
+				}
+				if view.IsBaseElement(name) != (want == 0) {
+					t.Errorf("IsBaseElement(%s) = %v", name, view.IsBaseElement(name))
+				}
+			}
+		})
+	}
+}
+
+func TestGraphViewRecipes(t *testing.T) {
+	setTestGraph(t)
+
+	tests := []struct {
+		policy  string
+		recipes map[string][]string // Elements without recipes are left out
+	}{
+		{
+			policy: RecipePolicyStrictTier,
+			recipes: map[string][]string{
+				"Steam": {"Water + Fire"},
+				"Cloud": {"Steam + Air"},
+				"Lava":  {"Earth + Fire"},
+				"Stone": {"Lava + Air"},
+				"Mud":   {"Water + Fire"},
+				"Clay":  {"Fire + Mud"},
+				"Brick": {"Air + Clay"},
+				"Kiln":  {"Fire + Brick"},
+			},
+		},
+		{
+			// The recipes through Cloud make Steam and Water out of
+			// themselves and Earth + Clay makes Mud out of Mud, Lava +
+			// Water does not
+			policy: RecipePolicyAcyclicOnly,
+			recipes: map[string][]string{
+				"Steam": {"Water + Fire", "Lava + Water"},
+				"Cloud": {"Steam + Air"},
+				"Lava":  {"Earth + Fire"},
+				"Stone": {"Lava + Air"},
+				"Mud":   {"Water + Fire"},
+				"Clay":  {"Fire + Mud"},
+				"Brick": {"Air + Clay"},
+				"Kiln":  {"Fire + Brick"},
+			},
+		},
+		{
+			// Lowest tier ingredients first, unreachable ones last
+			policy: RecipePolicyNone,
+			recipes: map[string][]string{
+				"Water": {"Cloud + Earth"},
+				"Steam": {"Water + Fire", "Lava + Water", "Cloud + Fire"},
+				"Cloud": {"Steam + Air"},
+				"Lava":  {"Earth + Fire"},
+				"Stone": {"Lava + Air", "Stone + Stone"},
+				"Mud":   {"Water + Fire", "Earth + Clay"},
+				"Clay":  {"Fire + Mud"},
+				"Brick": {"Air + Clay", "Brick + Clay"},
+				"Kiln":  {"Fire + Brick"},
+				"Ghost": {"Ghost + Air"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			view, err := NewGraphView(DefaultBaseElements, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recipes := map[string][]string{}
+			for name, node := range nameToNode {
+				for _, recipe := range view.RecipesFor(node) {
+					recipes[name] = append(recipes[name], recipe.ElementOne.Name+" + "+recipe.ElementTwo.Name)
+				}
+			}
+			if !reflect.DeepEqual(recipes, tt.recipes) {
+				t.Errorf("recipes = %v\nwant %v", recipes, tt.recipes)
+			}
+		})
+	}
+}
+
+// Both searches must find the same trees whatever the policy lets them use.
+func TestSearchesAgreeOnPolicies(t *testing.T) {
+	setTestGraph(t)
+
+	for _, policy := range []string{RecipePolicyStrictTier, RecipePolicyAcyclicOnly, RecipePolicyNone} {
+		for _, target := range []string{"Steam", "Cloud", "Stone", "Kiln"} {
+			found := map[string][]string{}
+			for _, mode := range []string{"bfs", "dfs"} {
+				var nodes int32
+				trees, err := GenerateRecipeTree(context.Background(), target, mode, 10, nil, policy, nil, time.Now(), &nodes)
+				if err != nil {
+					t.Fatalf("%s %s %s: %v", policy, mode, target, err)
+				}
+				for _, tree := range trees {
+					found[mode] = append(found[mode], treeToString(tree))
+				}
+				slices.Sort(found[mode])
+			}
+			if !slices.Equal(found["bfs"], found["dfs"]) {
+				t.Errorf("%s %s: bfs found %s\ndfs found %s", policy, target,
+					strings.Join(found["bfs"], ", "), strings.Join(found["dfs"], ", "))
+			}
+		}
+	}
+}
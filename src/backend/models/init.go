@@ -1,31 +1,82 @@
 package models
 
 import (
-	"fmt"
+	"ccp/backend/metrics"
+	"log/slog"
+	"os"
 	"slices"
+	"strings"
 )
 
 func Init() {
 	InitElementsGraph()
+	InitSprites()
 }
 
-var baseElements = []string{"Air", "Earth", "Fire", "Water"}
+// DefaultBaseElements is the starting set used when BASE_ELEMENTS is unset.
+var DefaultBaseElements = []string{"Air", "Earth", "Fire", "Water"}
+
+var (
+	baseElements []string
+	defaultView  *GraphView
+
+	// Element names in dataset order, used when the graph is saved back
+	elementOrder []string
+	// Metadata of the loaded dataset, its Elements are not kept up to date
+	loadedDataset *Dataset
+)
 
 func InitElementsGraph() {
-	elements, err := LoadElementsFromJSON("./data/elements.json")
+	dataPath := DataPath()
+	if _, err := os.Stat(CustomDataPath()); err == nil {
+		dataPath = CustomDataPath()
+	}
+	slog.Info("Loading elements", "path", dataPath)
+
+	dataset, err := LoadDatasetFromJSON(dataPath)
 	if err != nil {
 		panic(err)
 	}
+	if dataset.SchemaVersion == 0 {
+		slog.Info("Dataset has no schema version, loading it as a bare element list")
+	}
+	buildElementsGraph(dataset)
+}
+
+// ReloadElementsGraph replaces the live graph with dataset. Searches running
+// on the old graph finish first.
+func ReloadElementsGraph(dataset *Dataset) {
+	graphMu.Lock()
+	defer graphMu.Unlock()
+	buildElementsGraph(dataset)
+}
+
+// buildElementsGraph builds the graph of dataset from scratch.
+func buildElementsGraph(dataset *Dataset) {
+	nameToNode = make(map[string]*ElementsGraphNode)
+	ElementsGraph.RecipesToMakeOtherElement = []*Recipe{}
+	baseElements = nil
+	elementOrder = nil
+
+	elements := dataset.Elements
+	loadedDataset = dataset
+	invalidateSearchCache()
 
 	// Initialize the left side of the table (target-recipe) the target element
 	for _, el := range elements {
 		node := &ElementsGraphNode{
-			Name:                      el.Name,
-			ImagePath:                 el.ImagePath,
-			RecipesToMakeThisElement:  []*Recipe{},
-			RecipesToMakeOtherElement: []*Recipe{},
-			IsVisited:                 false,
-			Tier:                      -1,
+			Name:                        el.Name,
+			ImagePath:                   el.ImagePath,
+			RecipesToMakeThisElement:    []*Recipe{},
+			RecipesToMakeOtherElement:   []*Recipe{},
+			AllRecipesToMakeThisElement: []*Recipe{},
+			IsVisited:                   false,
+			Tier:                        -1,
+			Wiki:                        el.ElementWikiInfo,
+			Image:                       el.ElementImageInfo,
+		}
+		if _, ok := nameToNode[el.Name]; !ok {
+			elementOrder = append(elementOrder, el.Name)
 		}
 		nameToNode[el.Name] = node
 	}
@@ -50,34 +101,27 @@ func InitElementsGraph() {
 			}
 		}
 	}
-	
+
 	for _, node := range nameToNode {
 		node.MadeFrom = make(map[string]bool)
 	}
 
 	// Output the elements that are not found
 	if len(elementsNameNotFound) > 0 {
-		fmt.Println("Elements not found in the graph:")
-		for _, el := range elementsNameNotFound {
-			fmt.Println(el)
-		}
+		slog.Warn("Elements not found in the graph", "elements", elementsNameNotFound)
 	} else {
-		fmt.Println("All elements found in the graph")
+		slog.Info("All elements found in the graph", "elements", len(nameToNode))
 	}
 
 	// Populate all RecipesToMakeThisElement and RecipesToMakeOtherElement
+	isElement := func(name string) bool {
+		_, ok := nameToNode[name]
+		return ok
+	}
 	for _, el := range elements {
 		resultNode := nameToNode[el.Name]
-		for _, r := range el.Recipes {
-			if len(r) != 2 {
-				continue
-			}
-			ing1, ing2 := r[0], r[1]
-			node1, ok1 := nameToNode[ing1]
-			node2, ok2 := nameToNode[ing2]
-			if !ok1 || !ok2 {
-				continue
-			}
+		for _, r := range validRecipes(el.Recipes, isElement) {
+			node1, node2 := nameToNode[r[0]], nameToNode[r[1]]
 
 			// Check for duplicates before appending
 			recipe := &Recipe{
@@ -88,8 +132,8 @@ func InitElementsGraph() {
 
 			// Only add the recipe if it's not already present
 			// Avoid duplicate recipes for resultNode
-			if !containsRecipe(resultNode.RecipesToMakeThisElement, recipe) {
-				resultNode.RecipesToMakeThisElement = append(resultNode.RecipesToMakeThisElement, recipe)
+			if !containsRecipe(resultNode.AllRecipesToMakeThisElement, recipe) {
+				resultNode.AllRecipesToMakeThisElement = append(resultNode.AllRecipesToMakeThisElement, recipe)
 			}
 			if !containsRecipe(node1.RecipesToMakeOtherElement, recipe) {
 				node1.RecipesToMakeOtherElement = append(node1.RecipesToMakeOtherElement, recipe)
@@ -101,112 +145,102 @@ func InitElementsGraph() {
 		}
 	}
 
-	// Add basic elements to root node
-	basics := []string{"Air", "Earth", "Fire", "Water"}
-	for _, name := range basics {
-		if node, ok := nameToNode[name]; ok {
-			ElementsGraph.RecipesToMakeOtherElement = append(ElementsGraph.RecipesToMakeOtherElement, &Recipe{
-				ElementOne: node,
-				ElementTwo: nil,
-			})
-		}
-	}
-
-	// For every element that doesnt have recipe to make this element, append to basics
-	for _, node := range nameToNode {
-		if node.Name == "Air" || node.Name == "Earth" || node.Name == "Fire" || node.Name == "Water" {
-			node.Tier = 0
+	// Base elements are the configured starting set plus every element that
+	// has no recipe to make it
+	for _, name := range configuredBaseElements() {
+		if _, ok := nameToNode[name]; !ok {
+			slog.Warn("Configured base element not found in the graph", "element", name)
 			continue
 		}
-		if len(node.RecipesToMakeThisElement) == 0 {
-			ElementsGraph.RecipesToMakeOtherElement = append(ElementsGraph.RecipesToMakeOtherElement, &Recipe{
-				ElementOne: node,
-				ElementTwo: nil,
-			})
-			node.Tier = 0
-			baseElements = append(baseElements, node.Name)
+		if !slices.Contains(baseElements, name) {
+			baseElements = append(baseElements, name)
 		}
 	}
-
-	//  the tier for each node
-	curTier := 0
-
-	for {
-		nodesWithInitializedTier := map[string]bool{}
-		for _, node := range nameToNode {
-			if node.Tier != -1 {
-				nodesWithInitializedTier[node.Name] = true
-			}
+	var withoutRecipes []string
+	for _, node := range nameToNode {
+		if len(node.AllRecipesToMakeThisElement) == 0 && !slices.Contains(baseElements, node.Name) {
+			withoutRecipes = append(withoutRecipes, node.Name)
 		}
+	}
+	slices.Sort(withoutRecipes)
+	baseElements = append(baseElements, withoutRecipes...)
 
-		if len(nodesWithInitializedTier) == len(nameToNode) {
-			break
-		}
+	// Add base elements to root node
+	for _, name := range baseElements {
+		ElementsGraph.RecipesToMakeOtherElement = append(ElementsGraph.RecipesToMakeOtherElement, &Recipe{
+			ElementOne: nameToNode[name],
+			ElementTwo: nil,
+		})
+	}
 
-		// Set the tier for the next level
-		for _, node := range nameToNode {
-			if node.Tier != -1 {
-				continue
-			}
-			//check if the recipe to make this element is feasible
-			for _, recipe := range node.RecipesToMakeThisElement {
-				if nodesWithInitializedTier[recipe.ElementOne.Name] && (recipe.ElementTwo == nil || nodesWithInitializedTier[recipe.ElementTwo.Name]) {
-					node.Tier = curTier + 1
-					break
-				}
-			}
-		}
-		curTier++
+	// Tiers and the recipes kept for the searches come from the default view
+	// Element used in a recipe must have lower tier than the target node
+	defaultView = newGraphView(baseElements, RecipePolicyStrictTier)
+	for _, node := range nameToNode {
+		node.Tier = defaultView.Tier(node.Name)
+		node.RecipesToMakeThisElement = defaultView.RecipesFor(node)
 	}
 
+	buildNameIndex()
+
 	// Populate MadeFrom
 	for _, node := range nameToNode {
-		for _, recipe := range node.RecipesToMakeThisElement {
-			if recipe.ElementOne != nil {
-				node.MadeFrom[recipe.ElementOne.Name] = true
-				if recipe.ElementOne.MadeFrom != nil {
-					for made := range recipe.ElementOne.MadeFrom {
-						node.MadeFrom[made] = true
-					}
-				}
-			}
-			if recipe.ElementTwo != nil {
-				node.MadeFrom[recipe.ElementTwo.Name] = true
-				if recipe.ElementTwo.MadeFrom != nil {
-					for made := range recipe.ElementTwo.MadeFrom {
-						node.MadeFrom[made] = true
-					}
-				}
-			}
-		}
+		node.MadeFrom = madeFrom(node)
 	}
 
-	// Element used in a recipe must have lower tier than the target node
-	for _, node := range nameToNode {
-		// Create a new slice for recipes to keep
-		filtered := make([]*Recipe, 0, len(node.RecipesToMakeThisElement))
+	metrics.GraphReloads.Inc()
+	updateGraphMetrics()
+}
 
-		// Add only valid recipes to the filtered slice
-		for _, recipe := range node.RecipesToMakeThisElement {
-			shouldKeep := true
+// updateGraphMetrics reports the size of the live graph.
+func updateGraphMetrics() {
+	recipes := 0
+	for _, node := range nameToNode {
+		recipes += len(node.AllRecipesToMakeThisElement)
+	}
+	metrics.GraphElements.Set(float64(len(nameToNode)))
+	metrics.GraphRecipes.Set(float64(recipes))
+}
 
-			// Check if any element in the recipe has a tier >= the target node tier
-			if recipe.ElementOne != nil && recipe.ElementOne.Tier >= node.Tier {
-				shouldKeep = false
-			}
-			if recipe.ElementTwo != nil && recipe.ElementTwo.Tier >= node.Tier {
-				shouldKeep = false
-			}
+// configuredBaseElements is the starting set of the loaded dataset.
+func configuredBaseElements() []string {
+	var datasetBase []string
+	if loadedDataset != nil {
+		datasetBase = loadedDataset.BaseElements
+	}
+	return baseElementsFor(datasetBase)
+}
 
-			// Keep only valid recipes
-			if shouldKeep {
-				filtered = append(filtered, recipe)
-			}
+// baseElementsFor picks the starting set from BASE_ELEMENTS, a comma
+// separated list of element names, then from the dataset's base_elements and
+// finally falls back to DefaultBaseElements.
+func baseElementsFor(datasetBase []string) []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("BASE_ELEMENTS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
 		}
+	}
+	if len(names) > 0 {
+		return names
+	}
+	if len(datasetBase) > 0 {
+		return datasetBase
+	}
+	return DefaultBaseElements
+}
 
-		// Use slices to create a new slice (just to keep the import)
-		node.RecipesToMakeThisElement = slices.Clone(filtered)
+// validRecipes drops the recipes the graph cannot use, those without exactly
+// two ingredients or with an ingredient isElement does not know. An element
+// left without recipes is a base element.
+func validRecipes(recipes [][]string, isElement func(name string) bool) [][]string {
+	valid := make([][]string, 0, len(recipes))
+	for _, recipe := range recipes {
+		if len(recipe) == 2 && isElement(recipe[0]) && isElement(recipe[1]) {
+			valid = append(valid, recipe)
+		}
 	}
+	return valid
 }
 
 func containsRecipe(recipes []*Recipe, recipe *Recipe) bool {
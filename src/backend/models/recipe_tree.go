@@ -1,6 +1,8 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -16,6 +18,7 @@ func ValidateInputParams(
 	target string,
 	mode string,
 	maxTreeCount int,
+	view *GraphView,
 ) error {
 	if maxTreeCount <= 0 {
 		return fmt.Errorf("maxTreeCount must be greater than 0")
@@ -25,24 +28,52 @@ func ValidateInputParams(
 		return fmt.Errorf("elements graph is not initialized")
 	}
 
-	targetGraphNode, ok := nameToNode[target]
+	targetGraphNode, ok := GetElementsGraphNodeByName(target)
 	if !ok || targetGraphNode == nil {
 		return fmt.Errorf("target %s not found or is nil in elements graph", target)
 	}
+	target = targetGraphNode.Name
+
+	if !view.IsReachable(target) {
+		return fmt.Errorf("target %s cannot be made from base elements %v", target, view.BaseElements)
+	}
 
 	return nil
 }
 
+// searchView returns the view a search for target runs on and the name of
+// target in it, once the request is validated.
+func searchView(target string, mode string, maxTreeCount int, base []string, recipePolicy string) (*GraphView, string, error) {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+
+	view, err := getGraphView(base, recipePolicy)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := ValidateInputParams(target, mode, maxTreeCount, view); err != nil {
+		return nil, "", err
+	}
+	// Trees are named with the element's own spelling, not the alias asked for
+	target, _ = resolveElementName(target)
+	return view, target, nil
+}
+
 func GenerateRecipeTree(
+	ctx context.Context,
 	target string,
 	mode string,
 	maxTreeCount int,
+	base []string,
+	recipePolicy string,
 	signallerFn func(*RecipeTreeNode, int, int32),
-	delayMs int,
 	globalStartTime time.Time,
 	globalNodeCount *int32,
 ) ([]*RecipeTreeNode, error) {
-	if err := ValidateInputParams(target, mode, maxTreeCount); err != nil {
+	// The search runs on the view's snapshot of the graph, so recipe edits
+	// do not wait for it
+	view, target, err := searchView(target, mode, maxTreeCount, base, recipePolicy)
+	if err != nil {
 		return nil, err
 	}
 
@@ -51,77 +82,101 @@ func GenerateRecipeTree(
 		ImagePath: GetImagePath(target),
 	}
 
-	targetGraphNode, ok := nameToNode[target]
+	targetGraphNode, ok := view.Node(target)
 	if !ok || targetGraphNode == nil {
 		return nil, fmt.Errorf("target %s not found or is nil in elements graph", target)
 	}
 
-	var (
-		trees []*RecipeTreeNode
-		err   error
-	)
+	// Requests for more trees than allowed get at most the limit
+	limits := GetSearchLimits()
+	requestedTreeCount := maxTreeCount
+	if limits.MaxTrees > 0 && maxTreeCount > limits.MaxTrees {
+		maxTreeCount = limits.MaxTrees
+	}
+	searchCtx, budget, cancel := withSearchBudget(ctx, limits)
+	defer cancel()
 
-	if trees, err = ProcessRecipeTree(
+	trees, err := ProcessRecipeTree(
+		searchCtx,
 		rootRecipeTree,
 		targetGraphNode,
 		mode,
 		maxTreeCount,
 		signallerFn,
 		globalStartTime,
-		delayMs,
 		globalNodeCount,
-	); err != nil {
+		view,
+	)
+
+	// A search stopped by a limit keeps the trees it found
+	var limitErr *LimitExceededError
+	if errors.As(context.Cause(searchCtx), &limitErr) {
+		return trees, limitErr
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("search canceled: %w", ctx.Err())
+	}
+	if len(trees) < maxTreeCount && budget.depthExceeded.Load() {
+		return trees, &LimitExceededError{Limit: LimitMaxDepth, Max: int64(limits.MaxDepth)}
+	}
+	if err != nil {
 		return nil, err
 	}
-
 	if len(trees) == 0 {
 		return nil, fmt.Errorf("no complete tree found for target %s", target)
 	}
+	if requestedTreeCount > maxTreeCount && len(trees) >= maxTreeCount {
+		return trees, &LimitExceededError{Limit: LimitMaxTrees, Max: int64(limits.MaxTrees)}
+	}
 
 	return trees, nil
 }
 
 func ProcessRecipeTree(
+	ctx context.Context,
 	rootRecipeTree *RecipeTreeNode,
 	targetGraphNode *ElementsGraphNode,
 	mode string,
 	maxTreeCount int,
 	signalTreeChange func(*RecipeTreeNode, int, int32),
 	globalStartTime time.Time,
-	delayMs int,
 	globalNodeCounter *int32,
+	view *GraphView,
 ) ([]*RecipeTreeNode, error) {
 
 	if mode == "dfs" {
 		return DFSFindTrees(
+			ctx,
 			rootRecipeTree,
 			targetGraphNode,
 			maxTreeCount,
 			signalTreeChange,
 			globalStartTime,
 			globalNodeCounter,
-			delayMs,
+			view,
 		)
 	}
 	if mode == "bfs" {
 		return BFSFindTrees(
+			ctx,
 			targetGraphNode,
 			maxTreeCount,
 			signalTreeChange,
 			globalStartTime,
 			globalNodeCounter,
-			delayMs,
+			view,
 		)
 	}
 	if mode == "bidirectional" {
 		return BidirectionalFindTrees(
+			ctx,
 			rootRecipeTree,
 			targetGraphNode,
 			maxTreeCount,
 			signalTreeChange,
 			globalStartTime,
 			globalNodeCounter,
-			delayMs,
+			view,
 		)
 	}
 
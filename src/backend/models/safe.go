@@ -1,7 +1,5 @@
 package models
 
-import "fmt"
-
 type SafeRecipe struct {
 	ElementOneName    string `json:"element_one"`
 	ElementTwoName    string `json:"element_two,omitempty"` // omit if nil
@@ -50,7 +48,6 @@ func ToSafeGraph(root *ElementsGraphNode) []SafeElementNode {
 				ElementTwoName:    safeName(r.ElementTwo),
 				TargetElementName: r.TargetElementName,
 			})
-			fmt.Println("Adding recipe to make other element:", r.ElementOne.Name, r.ElementTwo, r.TargetElementName)
 			// Traverse result element
 			if target, ok := nameToNode[r.TargetElementName]; ok {
 				dfs(target)
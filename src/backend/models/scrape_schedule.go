@@ -0,0 +1,344 @@
+package models
+
+import (
+	"alchemy-scraper/scraper"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcomes of a scheduled scrape
+const (
+	ScrapeSwapped  = "swapped"  // The new dataset passed the checks and replaced the old one
+	ScrapeRejected = "rejected" // The new dataset is valid but changed more than the thresholds allow
+	ScrapeFailed   = "failed"   // The scraper failed or wrote an invalid dataset
+)
+
+// ScrapeConfig is read from the environment by ScrapeConfigFromEnv.
+type ScrapeConfig struct {
+	Interval    time.Duration `json:"-"`            // SCRAPE_INTERVAL, disabled when zero
+	Timeout     time.Duration `json:"-"`            // SCRAPE_TIMEOUT, default 30m
+	URL         string        `json:"url"`          // SCRAPE_URL, the wiki's elements page by default
+	Deep        bool          `json:"deep"`         // SCRAPE_DEEP, also scrape every element's own page
+	CacheDir    string        `json:"cache_dir"`    // SCRAPE_CACHE_DIR, default ./data/scrape-cache
+	StagingPath string        `json:"staging_path"` // SCRAPE_STAGING_PATH, default ./data/elements.staging.json
+	// SCRAPE_STAGING_PUBLIC_DIR, the public directory with .staging appended by default
+	StagingPublicDir string `json:"staging_public_dir"`
+
+	// Largest change accepted without review, SCRAPE_MAX_REMOVED_ELEMENTS,
+	// SCRAPE_MAX_REMOVED_RECIPES and SCRAPE_MAX_REACHABILITY_CHANGES
+	MaxRemovedElements     int `json:"max_removed_elements"`
+	MaxRemovedRecipes      int `json:"max_removed_recipes"`
+	MaxReachabilityChanges int `json:"max_reachability_changes"`
+}
+
+type ScrapeRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+
+	// Set once the staging dataset could be compared with the live one
+	AddedElements       int  `json:"added_elements"`
+	RemovedElements     int  `json:"removed_elements"`
+	AddedRecipes        int  `json:"added_recipes"`
+	RemovedRecipes      int  `json:"removed_recipes"`
+	ReachabilityChanges int  `json:"reachability_changes"`
+	ReloadedGraph       bool `json:"reloaded_graph"`
+	RebuiltSprites      bool `json:"rebuilt_sprites"`
+
+	Report *scraper.Report `json:"report,omitempty"` // Set once the scraper ran
+}
+
+type ScrapeStatus struct {
+	Enabled  bool         `json:"enabled"`
+	Interval string       `json:"interval"`
+	Timeout  string       `json:"timeout"`
+	Config   ScrapeConfig `json:"config"`
+	Running  bool         `json:"running"`
+	NextRun  *time.Time   `json:"next_run,omitempty"`
+	LastRun  *ScrapeRun   `json:"last_run,omitempty"`
+}
+
+var (
+	scrapeMu     sync.Mutex
+	scrapeStatus = ScrapeStatus{}
+)
+
+func ScrapeConfigFromEnv() (ScrapeConfig, error) {
+	config := ScrapeConfig{
+		Timeout:                30 * time.Minute,
+		URL:                    scraper.DefaultURL,
+		CacheDir:               "./data/scrape-cache",
+		StagingPath:            "./data/elements.staging.json",
+		StagingPublicDir:       filepath.Clean(PublicDir()) + ".staging",
+		MaxRemovedElements:     10,
+		MaxRemovedRecipes:      50,
+		MaxReachabilityChanges: 10,
+	}
+
+	var err error
+	if value := os.Getenv("SCRAPE_INTERVAL"); value != "" {
+		if config.Interval, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("invalid SCRAPE_INTERVAL: %w", err)
+		}
+	}
+	if value := os.Getenv("SCRAPE_TIMEOUT"); value != "" {
+		if config.Timeout, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("invalid SCRAPE_TIMEOUT: %w", err)
+		}
+	}
+	if value := os.Getenv("SCRAPE_DEEP"); value != "" {
+		if config.Deep, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("invalid SCRAPE_DEEP: %w", err)
+		}
+	}
+	for name, value := range map[string]*string{
+		"SCRAPE_URL":                &config.URL,
+		"SCRAPE_CACHE_DIR":          &config.CacheDir,
+		"SCRAPE_STAGING_PATH":       &config.StagingPath,
+		"SCRAPE_STAGING_PUBLIC_DIR": &config.StagingPublicDir,
+	} {
+		if env := os.Getenv(name); env != "" {
+			*value = env
+		}
+	}
+	for name, limit := range map[string]*int{
+		"SCRAPE_MAX_REMOVED_ELEMENTS":     &config.MaxRemovedElements,
+		"SCRAPE_MAX_REMOVED_RECIPES":      &config.MaxRemovedRecipes,
+		"SCRAPE_MAX_REACHABILITY_CHANGES": &config.MaxReachabilityChanges,
+	} {
+		if value := os.Getenv(name); value != "" {
+			if *limit, err = strconv.Atoi(value); err != nil {
+				return config, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+// StartScrapeScheduler re-scrapes the dataset every config.Interval until ctx
+// is done. It does nothing when the interval is zero.
+func StartScrapeScheduler(ctx context.Context, config ScrapeConfig) {
+	scrapeMu.Lock()
+	scrapeStatus.Config = config
+	scrapeStatus.Interval = config.Interval.String()
+	scrapeStatus.Timeout = config.Timeout.String()
+	scrapeStatus.Enabled = config.Interval > 0
+	scrapeMu.Unlock()
+	if config.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			next := time.Now().Add(config.Interval)
+			scrapeMu.Lock()
+			scrapeStatus.NextRun = &next
+			scrapeMu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(config.Interval):
+			}
+			RunScrape(ctx, config)
+		}
+	}()
+}
+
+func GetScrapeStatus() ScrapeStatus {
+	scrapeMu.Lock()
+	defer scrapeMu.Unlock()
+	return scrapeStatus
+}
+
+// RunScrape scrapes into the staging dataset and public directory and swaps
+// them in when the dataset is valid and within the thresholds. The graph is
+// reloaded unless an edited copy is being served, the sprite sheets are
+// rebuilt from the new icons.
+func RunScrape(ctx context.Context, config ScrapeConfig) *ScrapeRun {
+	scrapeMu.Lock()
+	if scrapeStatus.Running {
+		scrapeMu.Unlock()
+		return nil
+	}
+	scrapeStatus.Running = true
+	scrapeMu.Unlock()
+
+	run := &ScrapeRun{StartedAt: time.Now()}
+	runScrape(ctx, config, run)
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	level := slog.LevelInfo
+	if run.Outcome != ScrapeSwapped {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "Scheduled scrape finished", "outcome", run.Outcome, "reason", run.Reason, "duration_ms", run.DurationMs)
+
+	scrapeMu.Lock()
+	scrapeStatus.Running = false
+	scrapeStatus.LastRun = run
+	scrapeMu.Unlock()
+	return run
+}
+
+// Sprite sheets rebuilt after a scrape, as the scraper's sprites command
+// builds them by default
+const (
+	spriteCellSize  = 40
+	spriteColumns   = 32
+	spritesPerSheet = 1024
+)
+
+func runScrape(ctx context.Context, config ScrapeConfig, run *ScrapeRun) {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	aliases, err := loadAliasesFile(AliasesPath())
+	if err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("failed to load aliases: %v", err)
+		return
+	}
+	// Icons already downloaded are kept and only fetched again when changed
+	defer os.RemoveAll(config.StagingPublicDir)
+	if err := copyDir(PublicDir(), config.StagingPublicDir); err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("failed to stage public directory: %v", err)
+		return
+	}
+
+	dataset, report, err := scraper.Scrape(ctx, scraper.Options{
+		URL:       config.URL,
+		PublicDir: config.StagingPublicDir,
+		Aliases:   aliases,
+		Deep:      config.Deep,
+		Client: scraper.ClientOptions{
+			Concurrency: 4,
+			RatePerHost: 2,
+			Timeout:     30 * time.Second,
+			Retries:     3,
+			UserAgent:   "alchemy-scraper/1.0 (+https://github.com/yonatan-nyo/Tubes2_CCP)",
+			CacheDir:    config.CacheDir,
+		},
+	})
+	run.Report = &report
+	if err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("scraper failed: %v", err)
+		return
+	}
+	if err := writeScrapedDataset(dataset, config.StagingPath); err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("failed to write dataset: %v", err)
+		return
+	}
+	defer os.Remove(config.StagingPath)
+
+	staging, err := LoadDatasetFromJSON(config.StagingPath)
+	if err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("invalid dataset: %v", err)
+		return
+	}
+
+	live, err := LoadDatasetFromJSON(DataPath())
+	if err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("failed to load live dataset: %v", err)
+		return
+	}
+	diff := DiffDatasets(live, staging)
+	run.AddedElements = len(diff.AddedElements)
+	run.RemovedElements = len(diff.RemovedElements)
+	run.AddedRecipes = len(diff.AddedRecipes)
+	run.RemovedRecipes = len(diff.RemovedRecipes)
+	run.ReachabilityChanges = len(diff.ReachabilityChanges)
+
+	switch {
+	case run.RemovedElements > config.MaxRemovedElements:
+		run.Outcome, run.Reason = ScrapeRejected, fmt.Sprintf("%d elements removed, at most %d allowed", run.RemovedElements, config.MaxRemovedElements)
+		return
+	case run.RemovedRecipes > config.MaxRemovedRecipes:
+		run.Outcome, run.Reason = ScrapeRejected, fmt.Sprintf("%d recipes removed, at most %d allowed", run.RemovedRecipes, config.MaxRemovedRecipes)
+		return
+	case run.ReachabilityChanges > config.MaxReachabilityChanges:
+		run.Outcome, run.Reason = ScrapeRejected, fmt.Sprintf("%d reachability changes, at most %d allowed", run.ReachabilityChanges, config.MaxReachabilityChanges)
+		return
+	}
+
+	if err := swapScraped(config); err != nil {
+		run.Outcome, run.Reason = ScrapeFailed, fmt.Sprintf("failed to swap in the scrape: %v", err)
+		return
+	}
+	run.Outcome = ScrapeSwapped
+	run.Reason = "dataset and images replaced"
+
+	if err := scraper.BuildSprites(PublicDir(), spriteCellSize, spriteColumns, spritesPerSheet); err != nil {
+		run.Reason += fmt.Sprintf(", failed to rebuild sprites: %v", err)
+	} else {
+		InitSprites()
+		run.RebuiltSprites = true
+	}
+
+	// Edits are saved to CustomDataPath and keep being served over the scrape
+	if _, err := os.Stat(CustomDataPath()); err == nil {
+		run.Reason += ", graph kept because the edited copy is served"
+		return
+	}
+	ReloadElementsGraph(staging)
+	run.ReloadedGraph = true
+	run.Reason += ", graph reloaded"
+}
+
+// writeScrapedDataset writes dataset the way the scraper command does.
+func writeScrapedDataset(dataset scraper.Dataset, filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(dataset, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// copyDir replaces dst with a copy of src, or with an empty directory when
+// src does not exist yet.
+func copyDir(src string, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(dst, os.ModePerm)
+	}
+	return os.CopyFS(dst, os.DirFS(src))
+}
+
+// swapScraped moves the staging public directory and dataset in place of
+// the live ones with renames. The live directory is kept aside until both
+// are in and put back when the dataset cannot be replaced, so the images
+// never get ahead of the dataset that refers to them.
+func swapScraped(config ScrapeConfig) error {
+	publicDir := filepath.Clean(PublicDir())
+	previous := publicDir + ".previous"
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+	if err := os.Rename(publicDir, previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(config.StagingPublicDir, publicDir); err != nil {
+		os.Rename(previous, publicDir)
+		return err
+	}
+	if err := os.Rename(config.StagingPath, DataPath()); err != nil {
+		os.RemoveAll(publicDir)
+		os.Rename(previous, publicDir)
+		return err
+	}
+	return os.RemoveAll(previous)
+}
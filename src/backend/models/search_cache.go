@@ -0,0 +1,318 @@
+package models
+
+import (
+	"ccp/backend/metrics"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// graphVersion changes whenever the graph is rebuilt or edited, cached
+// results of older versions are never used.
+var graphVersion uint64
+
+// CacheOptions control how GenerateRecipeTreeCached uses the result cache.
+type CacheOptions struct {
+	Bypass bool // Always run the search, the result still replaces the cached one
+	Replay bool // On a hit, send the recorded exploration updates to the signaller
+	// Records the exploration updates of the search, which the cached result
+	// shares for replays. A recorder of its own is used when nil.
+	Recorder *EventRecorder
+}
+
+// CacheInfo describes where the trees of a search came from.
+type CacheInfo struct {
+	Hit            bool       `json:"hit"`
+	DatasetVersion uint64     `json:"dataset_version"`
+	CachedAt       *time.Time `json:"cached_at,omitempty"`
+	// Duration and nodes explored of the search that filled the cache
+	OriginalDurationMs int   `json:"original_duration_ms,omitempty"`
+	NodesExplored      int32 `json:"nodes_explored,omitempty"`
+	Replayed           bool  `json:"replayed,omitempty"`
+
+	entry *cacheEntry // The entry the result is cached in, nil when it is not
+}
+
+// SavedSearchID is the id of the saved search of the cached result, "" when
+// it was not saved.
+func (info *CacheInfo) SavedSearchID() string {
+	if info == nil || info.entry == nil {
+		return ""
+	}
+	resultCache.mu.Lock()
+	defer resultCache.mu.Unlock()
+	return info.entry.searchID
+}
+
+// SetSavedSearchID records the id the search was saved under, so the hits
+// on its result link to that saved search instead of saving another one.
+func (info *CacheInfo) SetSavedSearchID(id string) {
+	if info == nil || info.entry == nil {
+		return
+	}
+	resultCache.mu.Lock()
+	defer resultCache.mu.Unlock()
+	info.entry.searchID = id
+}
+
+type cacheEntry struct {
+	key           string
+	trees         []*RecipeTreeNode
+	events        []SearchEvent // As many as the recorder kept
+	durationMs    int
+	nodesExplored int32
+	cachedAt      time.Time
+	size          int
+	searchID      string // Saved search of the result, guarded by the cache's mu
+}
+
+// searchCache is an LRU of search results bounded by their JSON size.
+type searchCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List // Most recently used first
+	entries  map[string]*list.Element
+}
+
+var resultCache = newSearchCache(searchCacheBytes())
+
+// searchCacheBytes is the cache size from SEARCH_CACHE_BYTES, 64 MiB by
+// default. Zero disables the cache.
+func searchCacheBytes() int {
+	if value := os.Getenv("SEARCH_CACHE_BYTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 64 << 20
+}
+
+func newSearchCache(maxBytes int) *searchCache {
+	return &searchCache{maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *searchCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+// put adds entry, it reports false when entry is too large to be cached.
+func (c *searchCache) put(entry *cacheEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.size > c.maxBytes {
+		return false
+	}
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += entry.size
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+	c.updateMetrics()
+	return true
+}
+
+func (c *searchCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+func (c *searchCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	c.bytes = 0
+	c.updateMetrics()
+}
+
+func (c *searchCache) updateMetrics() {
+	metrics.SearchCacheEntries.Set(float64(len(c.entries)))
+	metrics.SearchCacheBytes.Set(float64(c.bytes))
+}
+
+// SearchCacheStats returns the number of cached results and their size.
+func SearchCacheStats() (entries int, bytes int) {
+	resultCache.mu.Lock()
+	defer resultCache.mu.Unlock()
+	return len(resultCache.entries), resultCache.bytes
+}
+
+// invalidateSearchCache drops every cached result after the graph changed.
+// The caller holds graphMu for writing.
+func invalidateSearchCache() {
+	graphVersion++
+	resultCache.clear()
+}
+
+// searchCacheKey identifies a search on the current graph and returns the
+// canonical name of its target. It reports false when the search cannot be
+// cached, e.g. for an unknown target, so the search reports the error itself.
+func searchCacheKey(target string, mode string, maxTreeCount int, base []string, recipePolicy string) (string, string, uint64, bool) {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+
+	target, ok := resolveElementName(target)
+	if !ok {
+		return "", "", 0, false
+	}
+	// A view depends on the set of base elements, not their order
+	names := make([]string, 0, len(base))
+	for _, name := range base {
+		canonical, ok := resolveElementName(name)
+		if !ok {
+			return "", "", 0, false
+		}
+		names = append(names, canonical)
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	if recipePolicy == "" {
+		recipePolicy = RecipePolicyStrictTier
+	}
+	if limit := GetSearchLimits().MaxTrees; limit > 0 && maxTreeCount > limit {
+		maxTreeCount = limit
+	}
+
+	key := strings.Join([]string{
+		strconv.FormatUint(graphVersion, 10),
+		target,
+		mode,
+		strconv.Itoa(maxTreeCount),
+		strings.Join(names, ","),
+		recipePolicy,
+	}, "\x00")
+	return key, target, graphVersion, true
+}
+
+// GenerateRecipeTreeCached is GenerateRecipeTree behind the result cache.
+// Only searches that complete without an error are cached. It also returns
+// the canonical name of target, or target itself when it is not an element.
+func GenerateRecipeTreeCached(
+	ctx context.Context,
+	target string,
+	mode string,
+	maxTreeCount int,
+	base []string,
+	recipePolicy string,
+	signallerFn func(*RecipeTreeNode, int, int32),
+	globalStartTime time.Time,
+	globalNodeCount *int32,
+	options CacheOptions,
+) ([]*RecipeTreeNode, string, *CacheInfo, error) {
+	key, canonicalTarget, version, cacheable := searchCacheKey(target, mode, maxTreeCount, base, recipePolicy)
+	if !cacheable {
+		canonicalTarget = target
+	}
+
+	// The updates are recorded once, for the saved search and the cache
+	recorder := options.Recorder
+	if recorder == nil {
+		recorder = NewEventRecorder(globalStartTime)
+	}
+	recordingSignaller := func(tree *RecipeTreeNode, durationMs int, nodesExplored int32) {
+		recorder.Record(tree, durationMs, nodesExplored)
+		if signallerFn != nil {
+			signallerFn(tree, durationMs, nodesExplored)
+		}
+	}
+
+	if !cacheable || resultCache.maxBytes == 0 {
+		trees, err := GenerateRecipeTree(ctx, target, mode, maxTreeCount, base, recipePolicy, recordingSignaller, globalStartTime, globalNodeCount)
+		return trees, canonicalTarget, nil, err
+	}
+
+	if !options.Bypass {
+		if entry, ok := resultCache.get(key); ok {
+			metrics.SearchCacheHits.Inc()
+			info := &CacheInfo{
+				Hit:                true,
+				DatasetVersion:     version,
+				CachedAt:           &entry.cachedAt,
+				OriginalDurationMs: entry.durationMs,
+				NodesExplored:      entry.nodesExplored,
+				entry:              entry,
+			}
+			if options.Replay && len(entry.events) > 0 && signallerFn != nil {
+				if err := replayEvents(ctx, entry.events, signallerFn); err != nil {
+					return nil, canonicalTarget, info, err
+				}
+				info.Replayed = true
+			}
+			// The controllers replace trees in the slice they get
+			return slices.Clone(entry.trees), canonicalTarget, info, nil
+		}
+	}
+
+	metrics.SearchCacheMisses.Inc()
+
+	trees, err := GenerateRecipeTree(ctx, target, mode, maxTreeCount, base, recipePolicy, recordingSignaller, globalStartTime, globalNodeCount)
+	info := &CacheInfo{DatasetVersion: version}
+	if err != nil {
+		return trees, canonicalTarget, info, err
+	}
+
+	entry := &cacheEntry{
+		key:           key,
+		trees:         slices.Clone(trees),
+		durationMs:    int(time.Since(globalStartTime).Milliseconds()),
+		nodesExplored: atomic.LoadInt32(globalNodeCount),
+		cachedAt:      time.Now().UTC(),
+	}
+	entry.events, _ = recorder.Events()
+	entry.size = entrySize(entry)
+
+	// Results computed while the graph changed belong to no version
+	graphMu.RLock()
+	if graphVersion == version && resultCache.put(entry) {
+		info.entry = entry
+	}
+	graphMu.RUnlock()
+	return trees, canonicalTarget, info, nil
+}
+
+// replayEvents sends recorded updates to signallerFn, whose stream paces
+// them like those of a search.
+func replayEvents(ctx context.Context, events []SearchEvent, signallerFn func(*RecipeTreeNode, int, int32)) error {
+	for _, event := range events {
+		if ctx.Err() != nil {
+			return fmt.Errorf("search canceled: %w", ctx.Err())
+		}
+		signallerFn(event.Tree, event.DurationMs, event.NodesExplored)
+	}
+	return nil
+}
+
+// entrySize is the JSON size of the trees and updates of entry, close to
+// what they take in memory.
+func entrySize(entry *cacheEntry) int {
+	size := len(entry.key)
+	for _, tree := range entry.trees {
+		data, _ := json.Marshal(tree)
+		size += len(data)
+	}
+	for _, event := range entry.events {
+		data, _ := json.Marshal(event.Tree)
+		size += len(data)
+	}
+	return size
+}
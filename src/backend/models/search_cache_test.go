@@ -0,0 +1,198 @@
+package models
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// loadTestGraph replaces the live graph with a small dataset:
+//
+//	Steam = Water + Fire, Mud = Water + Earth, Lava = Earth + Fire,
+//	Geyser = Steam + Earth, and "Vapour" as an alias of Steam.
+func loadTestGraph(t *testing.T) {
+	t.Helper()
+	t.Setenv("ALIASES_PATH", filepath.Join(t.TempDir(), "aliases.json"))
+	t.Setenv("BASE_ELEMENTS", "")
+	ReloadElementsGraph(&Dataset{
+		SchemaVersion: 1,
+		Name:          "test",
+		BaseElements:  []string{"Air", "Earth", "Fire", "Water"},
+		Aliases:       map[string]string{"Vapour": "Steam"},
+		Elements: []Element{
+			{Name: "Air", Recipes: [][]string{}},
+			{Name: "Earth", Recipes: [][]string{}},
+			{Name: "Fire", Recipes: [][]string{}},
+			{Name: "Water", Recipes: [][]string{}},
+			{Name: "Steam", Recipes: [][]string{{"Water", "Fire"}}},
+			{Name: "Mud", Recipes: [][]string{{"Water", "Earth"}}},
+			{Name: "Lava", Recipes: [][]string{{"Earth", "Fire"}}},
+			{Name: "Geyser", Recipes: [][]string{{"Steam", "Earth"}}},
+		},
+	})
+}
+
+func TestSearchCacheKey(t *testing.T) {
+	loadTestGraph(t)
+	defer SetSearchLimits(GetSearchLimits())
+	SetSearchLimits(SearchLimits{MaxTrees: 10})
+
+	type search struct {
+		target       string
+		mode         string
+		maxTreeCount int
+		base         []string
+		recipePolicy string
+	}
+	steam := search{"Steam", "bfs", 1, nil, ""}
+
+	tests := []struct {
+		name string
+		a, b search
+		same bool
+	}{
+		{"target case and spaces", steam, search{"  steam ", "bfs", 1, nil, ""}, true},
+		{"target alias", steam, search{"vapour", "bfs", 1, nil, ""}, true},
+		{"default recipe policy", steam, search{"Steam", "bfs", 1, nil, RecipePolicyStrictTier}, true},
+		{"tree count above the limit", search{"Steam", "bfs", 50, nil, ""}, search{"Steam", "bfs", 100, nil, ""}, true},
+		{
+			"base order, case and duplicates",
+			search{"Steam", "bfs", 1, []string{"Water", "Fire"}, ""},
+			search{"Steam", "bfs", 1, []string{"fire", "Water", "FIRE"}, ""},
+			true,
+		},
+		{"mode", steam, search{"Steam", "dfs", 1, nil, ""}, false},
+		{"recipe policy", steam, search{"Steam", "bfs", 1, nil, RecipePolicyNone}, false},
+		{"base", steam, search{"Steam", "bfs", 1, []string{"Water", "Fire"}, ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyA, targetA, _, okA := searchCacheKey(tt.a.target, tt.a.mode, tt.a.maxTreeCount, tt.a.base, tt.a.recipePolicy)
+			keyB, targetB, _, okB := searchCacheKey(tt.b.target, tt.b.mode, tt.b.maxTreeCount, tt.b.base, tt.b.recipePolicy)
+			if !okA || !okB {
+				t.Fatalf("searches not cacheable: %v, %v", okA, okB)
+			}
+			if (keyA == keyB) != tt.same {
+				t.Errorf("same key = %v, want %v", keyA == keyB, tt.same)
+			}
+			if tt.same && targetA != targetB {
+				t.Errorf("targets = %q and %q, want the same", targetA, targetB)
+			}
+		})
+	}
+
+	for _, s := range []search{{"Plasma", "bfs", 1, nil, ""}, {"Steam", "bfs", 1, []string{"Plasma"}, ""}} {
+		if _, _, _, ok := searchCacheKey(s.target, s.mode, s.maxTreeCount, s.base, s.recipePolicy); ok {
+			t.Errorf("search for %s from %v is cacheable, want unknown names to be searched", s.target, s.base)
+		}
+	}
+	if _, target, _, _ := searchCacheKey("vapour", "bfs", 1, nil, ""); target != "Steam" {
+		t.Errorf("canonical target = %q, want Steam", target)
+	}
+}
+
+func TestSearchCacheInvalidation(t *testing.T) {
+	loadTestGraph(t)
+
+	search := func() *CacheInfo {
+		t.Helper()
+		nodes := int32(0)
+		trees, _, info, err := GenerateRecipeTreeCached(context.Background(), "Geyser", "bfs", 1, nil, "", nil, time.Now(), &nodes, CacheOptions{})
+		if err != nil || len(trees) == 0 {
+			t.Fatalf("search failed: %v, %d trees", err, len(trees))
+		}
+		return info
+	}
+
+	keyBefore, _, versionBefore, _ := searchCacheKey("Geyser", "bfs", 1, nil, "")
+	if info := search(); info.Hit {
+		t.Errorf("first search hit the cache")
+	}
+	if info := search(); !info.Hit || info.DatasetVersion != versionBefore {
+		t.Errorf("second search: hit = %v, version = %d, want a hit on version %d", info.Hit, info.DatasetVersion, versionBefore)
+	}
+
+	loadTestGraph(t)
+	keyAfter, _, versionAfter, _ := searchCacheKey("Geyser", "bfs", 1, nil, "")
+	if versionAfter == versionBefore || keyAfter == keyBefore {
+		t.Errorf("graph version %d and key did not change after a reload", versionAfter)
+	}
+	if entries, bytes := SearchCacheStats(); entries != 0 || bytes != 0 {
+		t.Errorf("cache holds %d entries of %d bytes after a reload, want none", entries, bytes)
+	}
+	if info := search(); info.Hit {
+		t.Errorf("search after a reload hit the cache")
+	}
+}
+
+func TestSearchCacheLRU(t *testing.T) {
+	type op struct {
+		put  string // Key of an entry to add
+		size int
+		get  string // Key to look up instead
+	}
+
+	tests := []struct {
+		name  string
+		max   int
+		ops   []op
+		keys  []string // Cached keys, most recently used first
+		bytes int
+	}{
+		{
+			name:  "evicts least recently used",
+			max:   100,
+			ops:   []op{{put: "a", size: 40}, {put: "b", size: 40}, {put: "c", size: 40}},
+			keys:  []string{"c", "b"},
+			bytes: 80,
+		},
+		{
+			name:  "get refreshes an entry",
+			max:   100,
+			ops:   []op{{put: "a", size: 40}, {put: "b", size: 40}, {get: "a"}, {put: "c", size: 40}},
+			keys:  []string{"c", "a"},
+			bytes: 80,
+		},
+		{
+			name:  "replacing an entry counts it once",
+			max:   100,
+			ops:   []op{{put: "a", size: 40}, {put: "b", size: 40}, {put: "a", size: 20}},
+			keys:  []string{"a", "b"},
+			bytes: 60,
+		},
+		{
+			name:  "entry larger than the cache is not kept",
+			max:   100,
+			ops:   []op{{put: "a", size: 40}, {put: "b", size: 101}},
+			keys:  []string{"a"},
+			bytes: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newSearchCache(tt.max)
+			for _, op := range tt.ops {
+				if op.put != "" {
+					cache.put(&cacheEntry{key: op.put, size: op.size})
+				} else {
+					cache.get(op.get)
+				}
+			}
+
+			keys := []string{}
+			for element := cache.order.Front(); element != nil; element = element.Next() {
+				keys = append(keys, element.Value.(*cacheEntry).key)
+			}
+			if !slices.Equal(keys, tt.keys) || len(cache.entries) != len(tt.keys) {
+				t.Errorf("keys = %v (%d indexed), want %v", keys, len(cache.entries), tt.keys)
+			}
+			if cache.bytes != tt.bytes {
+				t.Errorf("bytes = %d, want %d", cache.bytes, tt.bytes)
+			}
+		})
+	}
+}
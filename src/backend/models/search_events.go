@@ -0,0 +1,136 @@
+package models
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SearchEvent is one exploration update of a search, as the signaller got it.
+type SearchEvent struct {
+	AtMs          int64           `json:"at_ms"` // Since the search started
+	DurationMs    int             `json:"duration_ms"`
+	NodesExplored int32           `json:"nodes_explored"`
+	Tree          *RecipeTreeNode `json:"tree"`
+}
+
+// maxSearchEvents is how many updates of a search are recorded, from
+// SEARCH_EVENTS_MAX. Zero disables recording.
+func maxSearchEvents() int {
+	if value := os.Getenv("SEARCH_EVENTS_MAX"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 10000
+}
+
+// EventRecorder records the updates of one search. The signaller may be
+// called from several goroutines.
+type EventRecorder struct {
+	mu        sync.Mutex
+	start     time.Time
+	max       int
+	events    []SearchEvent
+	truncated bool
+}
+
+func NewEventRecorder(start time.Time) *EventRecorder {
+	return &EventRecorder{start: start, max: maxSearchEvents()}
+}
+
+func (r *EventRecorder) Record(tree *RecipeTreeNode, durationMs int, nodesExplored int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) >= r.max {
+		r.truncated = r.max > 0
+		return
+	}
+	r.events = append(r.events, SearchEvent{
+		AtMs:          time.Since(r.start).Milliseconds(),
+		DurationMs:    durationMs,
+		NodesExplored: nodesExplored,
+		Tree:          tree,
+	})
+}
+
+// Events returns the recorded updates and whether later ones were left out.
+func (r *EventRecorder) Events() ([]SearchEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events, r.truncated
+}
+
+// The event log of a search sits next to it as gzipped JSON lines.
+func searchEventsFile(id string) string {
+	return filepath.Join(SearchesDir(), id+".events.jsonl.gz")
+}
+
+func writeSearchEvents(id string, events []SearchEvent) error {
+	filePath := searchEventsFile(id)
+	file, err := os.Create(filePath + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, event := range events {
+		if err = encoder.Encode(event); err != nil {
+			break
+		}
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(filePath+".tmp", filePath)
+	}
+	if err != nil {
+		os.Remove(filePath + ".tmp")
+	}
+	return err
+}
+
+// LoadSearchEvents reads the recorded updates of the saved search with id,
+// empty when it has none.
+func LoadSearchEvents(id string) ([]SearchEvent, error) {
+	if !searchIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrSearchNotFound, id)
+	}
+	file, err := os.Open(searchEventsFile(id))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(searchFile(id)); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrSearchNotFound, id)
+		}
+		return []SearchEvent{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("events of %s: %w", id, err)
+	}
+	events := []SearchEvent{}
+	decoder := json.NewDecoder(reader)
+	for decoder.More() {
+		var event SearchEvent
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("events of %s: %w", id, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SearchLimits bound the work a single search may do and how many searches
+// run at once. A zero limit is no limit.
+type SearchLimits struct {
+	MaxTrees           int           `json:"max_trees"`             // SEARCH_MAX_TREES
+	MaxDepth           int           `json:"max_depth"`             // SEARCH_MAX_DEPTH, deeper branches are pruned
+	MaxNodes           int32         `json:"max_nodes"`             // SEARCH_MAX_NODES, nodes explored
+	MaxDuration        time.Duration `json:"-"`                     // SEARCH_MAX_DURATION
+	MaxConcurrent      int           `json:"max_concurrent"`        // SEARCH_MAX_CONCURRENT, over all clients
+	MaxConcurrentPerIP int           `json:"max_concurrent_per_ip"` // SEARCH_MAX_CONCURRENT_PER_IP
+}
+
+var DefaultSearchLimits = SearchLimits{
+	MaxTrees:           100,
+	MaxDepth:           64,
+	MaxNodes:           1_000_000,
+	MaxDuration:        60 * time.Second,
+	MaxConcurrent:      32,
+	MaxConcurrentPerIP: 4,
+}
+
+var (
+	searchLimitsMu sync.RWMutex
+	searchLimits   = DefaultSearchLimits
+)
+
+// Names of the limits in LimitExceededError
+const (
+	LimitMaxTrees           = "max_trees"
+	LimitMaxDepth           = "max_depth"
+	LimitMaxNodes           = "max_nodes"
+	LimitMaxDuration        = "max_duration"
+	LimitMaxConcurrent      = "max_concurrent"
+	LimitMaxConcurrentPerIP = "max_concurrent_per_ip"
+)
+
+// LimitExceededError ends a search that ran into one of the SearchLimits.
+// GenerateRecipeTree returns it together with the trees found until then.
+type LimitExceededError struct {
+	Limit string `json:"limit"`
+	Max   int64  `json:"max"` // In milliseconds for max_duration
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.Limit == LimitMaxDuration {
+		return fmt.Sprintf("search limit exceeded: %s (%s)", e.Limit, time.Duration(e.Max)*time.Millisecond)
+	}
+	return fmt.Sprintf("search limit exceeded: %s (%d)", e.Limit, e.Max)
+}
+
+func SearchLimitsFromEnv() (SearchLimits, error) {
+	limits := DefaultSearchLimits
+	for name, limit := range map[string]*int{
+		"SEARCH_MAX_TREES":             &limits.MaxTrees,
+		"SEARCH_MAX_DEPTH":             &limits.MaxDepth,
+		"SEARCH_MAX_CONCURRENT":        &limits.MaxConcurrent,
+		"SEARCH_MAX_CONCURRENT_PER_IP": &limits.MaxConcurrentPerIP,
+	} {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return limits, fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*limit = parsed
+		}
+	}
+	if value := os.Getenv("SEARCH_MAX_NODES"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parsed < 0 {
+			return limits, fmt.Errorf("invalid SEARCH_MAX_NODES: %q", value)
+		}
+		limits.MaxNodes = int32(parsed)
+	}
+	if value := os.Getenv("SEARCH_MAX_DURATION"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			return limits, fmt.Errorf("invalid SEARCH_MAX_DURATION: %q", value)
+		}
+		limits.MaxDuration = parsed
+	}
+	return limits, nil
+}
+
+func SetSearchLimits(limits SearchLimits) {
+	searchLimitsMu.Lock()
+	defer searchLimitsMu.Unlock()
+	searchLimits = limits
+}
+
+func GetSearchLimits() SearchLimits {
+	searchLimitsMu.RLock()
+	defer searchLimitsMu.RUnlock()
+	return searchLimits
+}
+
+// searchBudget travels in the context of a search so the search loops can
+// check the node and depth limits without more parameters.
+type searchBudget struct {
+	maxNodes      int32
+	maxDepth      int
+	cancel        context.CancelCauseFunc
+	depthExceeded atomic.Bool
+}
+
+type searchBudgetKey struct{}
+
+// withSearchBudget returns a context that is canceled with a
+// LimitExceededError once the search runs out of nodes or time.
+func withSearchBudget(ctx context.Context, limits SearchLimits) (context.Context, *searchBudget, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	cancel := func() { cancelCause(context.Canceled) }
+	if limits.MaxDuration > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, limits.MaxDuration, &LimitExceededError{
+			Limit: LimitMaxDuration,
+			Max:   limits.MaxDuration.Milliseconds(),
+		})
+		cancel = func() {
+			cancelTimeout()
+			cancelCause(context.Canceled)
+		}
+	}
+
+	budget := &searchBudget{maxNodes: limits.MaxNodes, maxDepth: limits.MaxDepth, cancel: cancelCause}
+	return context.WithValue(ctx, searchBudgetKey{}, budget), budget, cancel
+}
+
+// exploreNode counts a node explored by the search and stops the search once
+// it has explored too many.
+func exploreNode(ctx context.Context, counter *int32) {
+	explored := atomic.AddInt32(counter, 1)
+	if budget, ok := ctx.Value(searchBudgetKey{}).(*searchBudget); ok && budget.maxNodes > 0 && explored > budget.maxNodes {
+		budget.cancel(&LimitExceededError{Limit: LimitMaxNodes, Max: int64(budget.maxNodes)})
+	}
+}
+
+// tooDeep reports whether a branch at depth goes past the depth limit. The
+// branch is pruned and the search reports the limit when it is done.
+func tooDeep(ctx context.Context, depth int) bool {
+	budget, ok := ctx.Value(searchBudgetKey{}).(*searchBudget)
+	if !ok || budget.maxDepth <= 0 || depth <= budget.maxDepth {
+		return false
+	}
+	budget.depthExceeded.Store(true)
+	return true
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SearchProgress is kept up to date by a running search for progress
+// reports. Nodes explored are counted in the search's node counter.
+type SearchProgress struct {
+	frontier atomic.Int64
+	trees    atomic.Int64
+}
+
+// Frontier is how much work the search has queued but not started: queued
+// elements in BFS and bidirectional, untried recipes in DFS.
+func (p *SearchProgress) Frontier() int64 {
+	return max(p.frontier.Load(), 0)
+}
+
+// TreesFound counts complete trees of the target, before duplicates are
+// removed.
+func (p *SearchProgress) TreesFound() int64 {
+	return p.trees.Load()
+}
+
+type searchProgressKey struct{}
+
+// WithSearchProgress returns a context whose search reports to progress.
+func WithSearchProgress(ctx context.Context, progress *SearchProgress) context.Context {
+	return context.WithValue(ctx, searchProgressKey{}, progress)
+}
+
+func addFrontier(ctx context.Context, delta int) {
+	if progress, ok := ctx.Value(searchProgressKey{}).(*SearchProgress); ok {
+		progress.frontier.Add(int64(delta))
+	}
+}
+
+func foundTree(ctx context.Context) {
+	if progress, ok := ctx.Value(searchProgressKey{}).(*SearchProgress); ok {
+		progress.trees.Add(1)
+	}
+}
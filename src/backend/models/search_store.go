@@ -0,0 +1,234 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSearchNotFound = errors.New("search not found")
+
+// SearchSummary describes a saved search without its trees.
+type SearchSummary struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Target        string    `json:"target"`
+	Mode          string    `json:"mode"`
+	MaxTreeCount  int       `json:"max_tree_count"`
+	Base          []string  `json:"base,omitempty"`
+	RecipePolicy  string    `json:"recipe_policy,omitempty"`
+	Dataset       string    `json:"dataset,omitempty"`
+	DurationMs    int       `json:"duration_ms"`
+	NodesExplored int32     `json:"nodes_explored"`
+	TreeCount     int       `json:"tree_count"`
+	// Recorded exploration updates, see GET /api/searches/{id}/events
+	EventCount      int  `json:"event_count"`
+	EventsTruncated bool `json:"events_truncated,omitempty"`
+}
+
+// SavedSearch is a completed search kept in SearchesDir so it can be shared
+// and shown again without running it.
+type SavedSearch struct {
+	SearchSummary
+	Trees []*RecipeTreeNode `json:"trees"`
+}
+
+// SearchesDir holds one JSON file per saved search, overridable with
+// SEARCHES_DIR.
+func SearchesDir() string {
+	if dir := os.Getenv("SEARCHES_DIR"); dir != "" {
+		return dir
+	}
+	return "./data/searches"
+}
+
+// maxSavedSearches is how many searches are kept from SEARCHES_MAX, the
+// oldest are deleted first. Zero keeps every search.
+func maxSavedSearches() int {
+	if value := os.Getenv("SEARCHES_MAX"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 1000
+}
+
+var searchIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+var (
+	searchStoreMu sync.Mutex
+	// Summaries of the saved searches, oldest first. Nil until loaded.
+	savedSearches []SearchSummary
+)
+
+// loadSavedSearches reads the summaries of SearchesDir once. The caller holds
+// searchStoreMu.
+func loadSavedSearches() {
+	if savedSearches != nil {
+		return
+	}
+	savedSearches = []SearchSummary{}
+
+	entries, err := os.ReadDir(SearchesDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to read saved searches", "dir", SearchesDir(), "error", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !searchIDPattern.MatchString(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(SearchesDir(), entry.Name()))
+		if err != nil {
+			slog.Warn("Failed to read saved search", "id", id, "error", err)
+			continue
+		}
+		var summary SearchSummary
+		if err := json.Unmarshal(data, &summary); err != nil || summary.ID != id {
+			slog.Warn("Skipping invalid saved search", "id", id, "error", err)
+			continue
+		}
+		savedSearches = append(savedSearches, summary)
+	}
+	slices.SortFunc(savedSearches, func(a, b SearchSummary) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	slog.Info("Loaded saved searches", "searches", len(savedSearches))
+}
+
+// SaveSearch stores search and its recorded updates under a new id, which it
+// sets and returns.
+func SaveSearch(search *SavedSearch, events []SearchEvent) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	search.ID = hex.EncodeToString(idBytes)
+	search.CreatedAt = time.Now().UTC()
+	search.TreeCount = len(search.Trees)
+	search.EventCount = len(events)
+	if search.Dataset == "" {
+		search.Dataset = loadedDatasetName()
+	}
+
+	searchStoreMu.Lock()
+	defer searchStoreMu.Unlock()
+	loadSavedSearches()
+
+	if err := os.MkdirAll(SearchesDir(), os.ModePerm); err != nil {
+		return "", err
+	}
+	if len(events) > 0 {
+		if err := writeSearchEvents(search.ID, events); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(search)
+	if err != nil {
+		return "", err
+	}
+	// Write to a temporary file first so readers never see half a search
+	filePath := searchFile(search.ID)
+	if err := os.WriteFile(filePath+".tmp", data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(filePath+".tmp", filePath); err != nil {
+		os.Remove(filePath + ".tmp")
+		return "", err
+	}
+	savedSearches = append(savedSearches, search.SearchSummary)
+
+	if limit := maxSavedSearches(); limit > 0 {
+		for len(savedSearches) > limit {
+			for _, path := range []string{searchFile(savedSearches[0].ID), searchEventsFile(savedSearches[0].ID)} {
+				if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					slog.Warn("Failed to delete old saved search", "id", savedSearches[0].ID, "error", err)
+				}
+			}
+			savedSearches = savedSearches[1:]
+		}
+	}
+	return search.ID, nil
+}
+
+// SavedSearchExists reports whether the search with id is still saved, old
+// searches are deleted to keep SEARCHES_MAX.
+func SavedSearchExists(id string) bool {
+	searchStoreMu.Lock()
+	defer searchStoreMu.Unlock()
+	loadSavedSearches()
+	return slices.ContainsFunc(savedSearches, func(summary SearchSummary) bool {
+		return summary.ID == id
+	})
+}
+
+// GetSavedSearch reads the saved search with id.
+func GetSavedSearch(id string) (*SavedSearch, error) {
+	if !searchIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrSearchNotFound, id)
+	}
+	data, err := os.ReadFile(searchFile(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSearchNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var search SavedSearch
+	if err := json.Unmarshal(data, &search); err != nil {
+		return nil, fmt.Errorf("saved search %s: %w", id, err)
+	}
+	return &search, nil
+}
+
+// ListSavedSearches returns saved searches newest first, optionally only
+// those for target, skipping offset and returning at most limit of them. It
+// also returns how many matched in total.
+func ListSavedSearches(target string, offset int, limit int) ([]SearchSummary, int) {
+	searchStoreMu.Lock()
+	defer searchStoreMu.Unlock()
+	loadSavedSearches()
+
+	matched := []SearchSummary{}
+	for i := len(savedSearches) - 1; i >= 0; i-- {
+		if target == "" || foldName(savedSearches[i].Target) == foldName(target) {
+			matched = append(matched, savedSearches[i])
+		}
+	}
+	total := len(matched)
+	if offset >= total {
+		return []SearchSummary{}, total
+	}
+	matched = matched[offset:]
+	if limit > 0 && limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched, total
+}
+
+func searchFile(id string) string {
+	return filepath.Join(SearchesDir(), id+".json")
+}
+
+func loadedDatasetName() string {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+	if loadedDataset == nil {
+		return ""
+	}
+	return loadedDataset.Name
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// useSearchStore points the saved searches at an empty directory keeping at
+// most limit searches and returns the directory.
+func useSearchStore(t *testing.T, limit int) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "searches")
+	t.Setenv("SEARCHES_DIR", dir)
+	t.Setenv("SEARCHES_MAX", strconv.Itoa(limit))
+	resetSavedSearches := func() {
+		searchStoreMu.Lock()
+		defer searchStoreMu.Unlock()
+		savedSearches = nil
+	}
+	resetSavedSearches()
+	t.Cleanup(resetSavedSearches)
+	return dir
+}
+
+func saveTestSearch(t *testing.T, target string, events int) string {
+	t.Helper()
+	recorded := make([]SearchEvent, events)
+	for i := range recorded {
+		recorded[i] = SearchEvent{AtMs: int64(i), NodesExplored: int32(i), Tree: &RecipeTreeNode{Name: target}}
+	}
+	id, err := SaveSearch(&SavedSearch{
+		SearchSummary: SearchSummary{Target: target, Mode: "bfs", MaxTreeCount: 1},
+		Trees:         []*RecipeTreeNode{{Name: target}},
+	}, recorded)
+	if err != nil {
+		t.Fatalf("saving a search for %s: %v", target, err)
+	}
+	return id
+}
+
+func TestSavedSearchIDs(t *testing.T) {
+	dir := useSearchStore(t, 0)
+	saved := saveTestSearch(t, "Steam", 2)
+	// A file outside the store that a crafted id could point at
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "outside.json"), []byte(`{"id":"outside"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		found bool
+	}{
+		{"saved", saved, true},
+		{"unknown", "0123456789abcdef", false},
+		{"too short", saved[:15], false},
+		{"upper case", "0123456789ABCDEF", false},
+		{"path", "../outside", false},
+		{"file name", saved + ".json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search, err := GetSavedSearch(tt.id)
+			if tt.found {
+				if err != nil || search.ID != tt.id {
+					t.Fatalf("GetSavedSearch(%q) = %v, %v", tt.id, search, err)
+				}
+			} else if !errors.Is(err, ErrSearchNotFound) {
+				t.Errorf("GetSavedSearch(%q) error = %v, want ErrSearchNotFound", tt.id, err)
+			}
+
+			events, err := LoadSearchEvents(tt.id)
+			if tt.found {
+				if err != nil || len(events) != 2 {
+					t.Errorf("LoadSearchEvents(%q) = %d events, %v, want 2", tt.id, len(events), err)
+				}
+			} else if !errors.Is(err, ErrSearchNotFound) {
+				t.Errorf("LoadSearchEvents(%q) error = %v, want ErrSearchNotFound", tt.id, err)
+			}
+
+			if exists := SavedSearchExists(tt.id); exists != tt.found {
+				t.Errorf("SavedSearchExists(%q) = %v, want %v", tt.id, exists, tt.found)
+			}
+		})
+	}
+}
+
+func TestSavedSearchLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int
+		saves int
+		kept  int
+	}{
+		{"under the limit", 5, 3, 3},
+		{"over the limit", 3, 7, 3},
+		{"no limit", 0, 7, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useSearchStore(t, tt.max)
+			ids := []string{}
+			for i := range tt.saves {
+				// Every other search has events, whose file goes with it
+				ids = append(ids, saveTestSearch(t, "Steam", i%2))
+			}
+			kept := ids[len(ids)-tt.kept:]
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantFiles := 0
+			for i, id := range ids {
+				exists := SavedSearchExists(id)
+				if want := slices.Contains(kept, id); exists != want {
+					t.Errorf("search %d exists = %v, want %v", i, exists, want)
+				}
+				if slices.Contains(kept, id) {
+					wantFiles += 1 + i%2
+				}
+			}
+			if len(files) != wantFiles {
+				t.Errorf("%d files in the store, want %d", len(files), wantFiles)
+			}
+
+			// The limit holds for the searches read back from disk too
+			searchStoreMu.Lock()
+			savedSearches = nil
+			searchStoreMu.Unlock()
+			summaries, total := ListSavedSearches("", 0, 0)
+			if total != tt.kept {
+				t.Fatalf("%d searches listed after a reload, want %d", total, tt.kept)
+			}
+			// Newest first
+			for i, summary := range summaries {
+				if want := kept[len(kept)-1-i]; summary.ID != want {
+					t.Errorf("search %d listed is %s, want %s", i, summary.ID, want)
+				}
+			}
+		})
+	}
+}
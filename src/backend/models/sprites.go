@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// SpriteAtlas maps element icons to their place in the sprite sheets built
+// by the scraper's `sprites` command. Sprites are keyed by icon file name.
+type SpriteAtlas struct {
+	CellSize int                  `json:"cell_size"`
+	Sheets   []SpriteSheet        `json:"sheets"`
+	Sprites  map[string]SpriteRef `json:"sprites"`
+}
+
+type SpriteSheet struct {
+	ID     int    `json:"id"`
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type SpriteRef struct {
+	Sheet int `json:"sheet"`
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	W     int `json:"w"`
+	H     int `json:"h"`
+}
+
+// Nil when no atlas was found, elements then have no sprite. Replaced when a
+// scheduled scrape rebuilds the sheets.
+var spriteAtlas atomic.Pointer[SpriteAtlas]
+
+// SpriteAtlasPath is the atlas written with the sprite sheets, overridable
+// with SPRITE_ATLAS_PATH.
+func SpriteAtlasPath() string {
+	if path := os.Getenv("SPRITE_ATLAS_PATH"); path != "" {
+		return path
+	}
+	return filepath.Join(PublicDir(), "sprites", "atlas.json")
+}
+
+func InitSprites() {
+	data, err := os.ReadFile(SpriteAtlasPath())
+	if err != nil {
+		slog.Info("No sprite atlas found, elements are served without sprites")
+		return
+	}
+
+	var atlas SpriteAtlas
+	if err := json.Unmarshal(data, &atlas); err != nil {
+		slog.Warn("Failed to parse sprite atlas", "error", err)
+		return
+	}
+	spriteAtlas.Store(&atlas)
+	slog.Info("Loaded sprite atlas", "sprites", len(atlas.Sprites), "sheets", len(atlas.Sheets))
+}
+
+// GetSpriteAtlas returns the atlas with sheet paths turned into URLs under
+// baseURL, or nil when there is none.
+func GetSpriteAtlas(baseURL string) *SpriteAtlas {
+	current := spriteAtlas.Load()
+	if current == nil {
+		return nil
+	}
+	atlas := *current
+	atlas.Sheets = make([]SpriteSheet, len(current.Sheets))
+	for i, sheet := range current.Sheets {
+		sheet.Path = AssetURL(baseURL, AssetKey(sheet.Path))
+		atlas.Sheets[i] = sheet
+	}
+	return &atlas
+}
+
+// spriteFor finds the sprite of the icon with asset key imageKey by its file name.
+func spriteFor(imageKey string) *SpriteRef {
+	atlas := spriteAtlas.Load()
+	if atlas == nil || imageKey == "" {
+		return nil
+	}
+	sprite, ok := atlas.Sprites[path.Base(imageKey)]
+	if !ok {
+		return nil
+	}
+	return &sprite
+}
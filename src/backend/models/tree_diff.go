@@ -0,0 +1,91 @@
+package models
+
+// Types of TreeEvent
+const (
+	TreeNodeAdded        = "node_added"
+	TreeNodeExpanded     = "node_expanded"     // The node got the two elements of a recipe
+	TreeSubtreeCompleted = "subtree_completed" // Every leaf of the subtree is a base element
+	TreeSubtreePruned    = "subtree_pruned"    // The node and everything below it were dropped
+)
+
+// TreeEvent is one change between two exploring trees. Node ids follow the
+// position of the node: the root is "0" and the elements of node "0.2" are
+// "0.2.1" and "0.2.2", so a node keeps its id while it stays in the tree.
+type TreeEvent struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Parent    string `json:"parent,omitempty"`
+	Slot      int    `json:"slot,omitempty"` // 1 for element_1, 2 for element_2
+	Name      string `json:"name,omitempty"`
+	ImagePath string `json:"image_path,omitempty"`
+}
+
+// TreeDiffer turns the exploring trees of a search into TreeEvents against
+// the tree it saw last.
+type TreeDiffer struct {
+	base     map[string]bool
+	previous *RecipeTreeNode
+	complete map[string]bool // Ids of the complete subtrees of previous
+}
+
+func NewTreeDiffer(baseElements []string) *TreeDiffer {
+	base := make(map[string]bool, len(baseElements))
+	for _, name := range baseElements {
+		base[name] = true
+	}
+	return &TreeDiffer{base: base, complete: map[string]bool{}}
+}
+
+// Diff returns the events that turn the previous tree into tree.
+func (d *TreeDiffer) Diff(tree *RecipeTreeNode) []TreeEvent {
+	events := []TreeEvent{}
+	complete := map[string]bool{}
+	d.diff(d.previous, tree, "0", "", 0, &events, complete)
+	d.previous = tree
+	d.complete = complete
+	return events
+}
+
+// Reset makes tree the previous tree without events, after the client got
+// all of it in a snapshot.
+func (d *TreeDiffer) Reset(tree *RecipeTreeNode) {
+	d.Diff(tree)
+}
+
+// diff compares the nodes at id and reports whether next is complete.
+func (d *TreeDiffer) diff(previous *RecipeTreeNode, next *RecipeTreeNode, id string, parent string, slot int, events *[]TreeEvent, complete map[string]bool) bool {
+	if previous != nil && (next == nil || previous.Name != next.Name) {
+		*events = append(*events, TreeEvent{Type: TreeSubtreePruned, ID: id})
+		previous = nil
+	}
+	if next == nil {
+		return false
+	}
+	if previous == nil {
+		*events = append(*events, TreeEvent{Type: TreeNodeAdded, ID: id, Parent: parent, Slot: slot, Name: next.Name, ImagePath: next.ImagePath})
+	}
+
+	hasChildren := next.Element1 != nil || next.Element2 != nil
+	if hasChildren && (previous == nil || (previous.Element1 == nil && previous.Element2 == nil)) {
+		*events = append(*events, TreeEvent{Type: TreeNodeExpanded, ID: id})
+	}
+
+	var previous1, previous2 *RecipeTreeNode
+	if previous != nil {
+		previous1, previous2 = previous.Element1, previous.Element2
+	}
+	complete1 := d.diff(previous1, next.Element1, id+".1", id, 1, events, complete)
+	complete2 := d.diff(previous2, next.Element2, id+".2", id, 2, events, complete)
+
+	if !hasChildren {
+		return d.base[next.Name]
+	}
+	if !complete1 || !complete2 {
+		return false
+	}
+	complete[id] = true
+	if previous == nil || !d.complete[id] {
+		*events = append(*events, TreeEvent{Type: TreeSubtreeCompleted, ID: id})
+	}
+	return true
+}
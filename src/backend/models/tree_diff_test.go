@@ -0,0 +1,110 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// treeNode builds a tree node made from the two given elements, or a leaf.
+func treeNode(name string, elements ...*RecipeTreeNode) *RecipeTreeNode {
+	node := &RecipeTreeNode{Name: name, ImagePath: name + ".png"}
+	if len(elements) == 2 {
+		node.Element1, node.Element2 = elements[0], elements[1]
+	}
+	return node
+}
+
+func TestTreeDiffer(t *testing.T) {
+	added := func(id string, parent string, slot int, name string) TreeEvent {
+		return TreeEvent{Type: TreeNodeAdded, ID: id, Parent: parent, Slot: slot, Name: name, ImagePath: name + ".png"}
+	}
+	expanded := func(id string) TreeEvent { return TreeEvent{Type: TreeNodeExpanded, ID: id} }
+	completed := func(id string) TreeEvent { return TreeEvent{Type: TreeSubtreeCompleted, ID: id} }
+	pruned := func(id string) TreeEvent { return TreeEvent{Type: TreeSubtreePruned, ID: id} }
+
+	tests := []struct {
+		name     string
+		previous *RecipeTreeNode // Sent in a snapshot before, nil for none
+		next     *RecipeTreeNode
+		events   []TreeEvent
+	}{
+		{
+			name:   "first base element",
+			next:   treeNode("Fire"),
+			events: []TreeEvent{added("0", "", 0, "Fire")},
+		},
+		{
+			name: "first complete tree",
+			next: treeNode("Steam", treeNode("Water"), treeNode("Fire")),
+			events: []TreeEvent{
+				added("0", "", 0, "Steam"),
+				expanded("0"),
+				added("0.1", "0", 1, "Water"),
+				added("0.2", "0", 2, "Fire"),
+				completed("0"),
+			},
+		},
+		{
+			name:     "leaf expanded until complete",
+			previous: treeNode("Geyser", treeNode("Steam"), treeNode("Earth")),
+			next:     treeNode("Geyser", treeNode("Steam", treeNode("Water"), treeNode("Fire")), treeNode("Earth")),
+			events: []TreeEvent{
+				expanded("0.1"),
+				added("0.1.1", "0.1", 1, "Water"),
+				added("0.1.2", "0.1", 2, "Fire"),
+				completed("0.1"),
+				completed("0"),
+			},
+		},
+		{
+			name:     "subtree replaced by another element",
+			previous: treeNode("Geyser", treeNode("Steam", treeNode("Water"), treeNode("Fire")), treeNode("Earth")),
+			next:     treeNode("Geyser", treeNode("Mud"), treeNode("Earth")),
+			events: []TreeEvent{
+				pruned("0.1"),
+				added("0.1", "0", 1, "Mud"),
+			},
+		},
+		{
+			name:     "other recipe for the same element",
+			previous: treeNode("Steam", treeNode("Water"), treeNode("Fire")),
+			next:     treeNode("Steam", treeNode("Water"), treeNode("Lava", treeNode("Earth"), treeNode("Fire"))),
+			events: []TreeEvent{
+				pruned("0.2"),
+				added("0.2", "0", 2, "Lava"),
+				expanded("0.2"),
+				added("0.2.1", "0.2", 1, "Earth"),
+				added("0.2.2", "0.2", 2, "Fire"),
+				completed("0.2"),
+			},
+		},
+		{
+			name:     "new root",
+			previous: treeNode("Steam", treeNode("Water"), treeNode("Fire")),
+			next:     treeNode("Mud"),
+			events:   []TreeEvent{pruned("0"), added("0", "", 0, "Mud")},
+		},
+		{
+			name:     "tree gone",
+			previous: treeNode("Steam"),
+			next:     nil,
+			events:   []TreeEvent{pruned("0")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differ := NewTreeDiffer([]string{"Air", "Earth", "Fire", "Water"})
+			if tt.previous != nil {
+				differ.Reset(tt.previous)
+			}
+			if events := differ.Diff(tt.next); !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %+v\nwant %+v", events, tt.events)
+			}
+			// The next diff starts from the tree just diffed
+			if events := differ.Diff(tt.next); len(events) != 0 {
+				t.Errorf("diffing the same tree again = %+v, want no events", events)
+			}
+		})
+	}
+}
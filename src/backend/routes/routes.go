@@ -2,6 +2,8 @@ package routes
 
 import (
 	"ccp/backend/controllers"
+	"ccp/backend/metrics"
+	"ccp/backend/models"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,11 +12,34 @@ import (
 func RegisterRoutes(mux *http.ServeMux) {
 	// API routes
 	mux.HandleFunc("/ws", controllers.WebSocketHandler)
+	mux.HandleFunc("GET /api/recipes/stream", controllers.RecipesStream)
 	mux.HandleFunc("/api/graph", controllers.GetElementsGraph)
 	mux.HandleFunc("/api/elements", controllers.ElementsGetAll)
+	mux.HandleFunc("GET /api/sprites", controllers.SpritesGet)
 
-	// Serve static assets from "public"
-	publicServer := http.FileServer(http.Dir("./public"))
+	// Saved searches, for permalinks to results
+	mux.HandleFunc("GET /api/searches", controllers.SearchesList)
+	mux.HandleFunc("GET /api/searches/{id}", controllers.SearchesGet)
+	mux.HandleFunc("GET /api/searches/{id}/events", controllers.SearchEventsGet)
+
+	// Recipe editing routes, require ADMIN_TOKEN
+	mux.HandleFunc("POST /api/elements", controllers.ElementsCreate)
+	mux.HandleFunc("DELETE /api/elements/{name}", controllers.ElementsDelete)
+	mux.HandleFunc("POST /api/elements/{name}/recipes", controllers.ElementRecipesCreate)
+	mux.HandleFunc("DELETE /api/elements/{name}/recipes", controllers.ElementRecipesDelete) // ?element_one=&element_two=
+
+	// Admin routes, require ADMIN_TOKEN
+	mux.HandleFunc("GET /api/admin/diff", controllers.AdminDiff)
+	mux.HandleFunc("GET /api/admin/scrape-status", controllers.AdminScrapeStatus)
+
+	// Prometheus metrics
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// Element icons and other assets by asset key
+	mux.HandleFunc("GET /assets/{key...}", controllers.AssetsGet)
+
+	// Serve static assets from "public", kept for URLs from older responses
+	publicServer := http.FileServer(http.Dir(models.PublicDir()))
 	mux.Handle("/public/", http.StripPrefix("/public", publicServer))
 
 	// Fallback: Serve frontend (SPA)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"ccp/backend/controllers"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// serverConfig is read from the environment by serverConfigFromEnv.
+type serverConfig struct {
+	Addr              string        // LISTEN_ADDR, default 0.0.0.0:4000
+	CertFile          string        // TLS_CERT_FILE, serves HTTPS together with TLS_KEY_FILE
+	KeyFile           string        // TLS_KEY_FILE
+	ReadHeaderTimeout time.Duration // HTTP_READ_HEADER_TIMEOUT, default 10s
+	ReadTimeout       time.Duration // HTTP_READ_TIMEOUT, default 30s
+	WriteTimeout      time.Duration // HTTP_WRITE_TIMEOUT, default 60s
+	IdleTimeout       time.Duration // HTTP_IDLE_TIMEOUT, default 120s
+	// SHUTDOWN_GRACE_PERIOD, how long running searches may finish after
+	// SIGTERM before they are canceled, default 10s
+	GracePeriod time.Duration
+}
+
+func serverConfigFromEnv() (serverConfig, error) {
+	config := serverConfig{
+		Addr:              "0.0.0.0:4000",
+		CertFile:          os.Getenv("TLS_CERT_FILE"),
+		KeyFile:           os.Getenv("TLS_KEY_FILE"),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		GracePeriod:       10 * time.Second,
+	}
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		config.Addr = addr
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return config, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	for name, duration := range map[string]*time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": &config.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":        &config.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":       &config.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        &config.IdleTimeout,
+		"SHUTDOWN_GRACE_PERIOD":    &config.GracePeriod,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*duration = parsed
+	}
+	return config, nil
+}
+
+// serve runs the server until ctx is done, then shuts it down: new searches
+// are refused, WebSocket clients are told, running searches get the grace
+// period to finish before they are canceled.
+func serve(ctx context.Context, config serverConfig, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if config.CertFile != "" {
+			slog.Info("Server started", "addr", config.Addr, "tls", true)
+			serveErr <- server.ListenAndServeTLS(config.CertFile, config.KeyFile)
+		} else {
+			slog.Info("Server started", "addr", config.Addr, "tls", false)
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutdown signal received", "grace_period", config.GracePeriod.String())
+	controllers.BeginShutdown()
+
+	graceCtx, cancel := context.WithTimeout(context.Background(), config.GracePeriod)
+	defer cancel()
+	if err := server.Shutdown(graceCtx); err != nil {
+		slog.Warn("Requests still running after the grace period", "error", err)
+	}
+	controllers.FinishShutdown(graceCtx)
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
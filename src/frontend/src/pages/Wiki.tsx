@@ -16,6 +16,11 @@ interface Element {
   recipes_to_make_other_element: Recipe[];
   is_visited: boolean;
   tier: number;
+  thumb_path?: string;
+  description?: string;
+  used_in?: number;
+  pack?: string;
+  is_final?: boolean;
   // Additional properties for UI use
   Name?: string;
   ImagePath?: string;
@@ -348,7 +353,7 @@ export default function Wiki() {
                         <td className="px-6 py-4 whitespace-nowrap">
                           <div className="flex items-center">
                             <div className="flex-shrink-0 h-10 w-10">
-                              <img className="h-10 w-10 object-contain" src={element.image_path} alt={element.name} />
+                              <img className="h-10 w-10 object-contain" src={element.thumb_path || element.image_path} alt={element.name} />
                             </div>
                             <div className="ml-4">
                               <div className="text-sm font-medium text-gray-900">{element.name}</div>
@@ -440,7 +445,10 @@ export default function Wiki() {
                     <div className="flex flex-wrap gap-2 mt-2">
                       <span className="text-sm px-2 py-1 bg-blue-100 text-blue-800 rounded-full">Tier {selectedElement.tier}</span>
                       {isElementConsideredBasic(selectedElement) && <span className="text-sm px-2 py-1 bg-purple-100 text-purple-800 rounded-full">Basic Element</span>}
+                      {selectedElement.pack && <span className="text-sm px-2 py-1 bg-green-100 text-green-800 rounded-full">{selectedElement.pack}</span>}
+                      {selectedElement.is_final && <span className="text-sm px-2 py-1 bg-yellow-100 text-yellow-800 rounded-full">Final Element</span>}
                     </div>
+                    {selectedElement.description && <p className="text-sm text-gray-600 mt-2">{selectedElement.description}</p>}
                   </div>
                 </div>
                 <button onClick={() => setSelectedElement(null)} className="text-gray-400 hover:text-gray-500">
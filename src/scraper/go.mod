@@ -2,7 +2,10 @@ module alchemy-scraper
 
 go 1.24.2
 
-require github.com/PuerkitoBio/goquery v1.10.3
+require (
+	github.com/PuerkitoBio/goquery v1.10.3
+	golang.org/x/image v0.27.0
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
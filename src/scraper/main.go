@@ -1,178 +1,109 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-	"sync"
-	"time"
-
-	"github.com/PuerkitoBio/goquery"
-)
-
-func main() {
-	url := "https://little-alchemy.fandom.com/wiki/Elements_(Little_Alchemy_2)"
-	elements := scrapeElements(url)
-
-	fmt.Printf("Total elemen ditemukan: %d\n", len(elements))
-	elements = getMissingElementsIngredients(elements, url)
-	fmt.Printf("Total elemen ditemukan: %d\n", len(elements))
-	saveElementsToFile(elements, "../backend/data/elements.json")
-}
-
-func scrapeElements(url string) []Element {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("Failed to fetch page: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Fatalf("Status code error: %d %s", resp.StatusCode, resp.Status)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Fatalf("Failed to parse HTML: %v", err)
-	}
-
-	var wg sync.WaitGroup
-	elementsChan := make(chan Element, 100) // Buffered channel to collect elements
-
-	doc.Find("table.list-table.col-list.icon-hover").Each(func(_ int, table *goquery.Selection) {
-		table.Find("tr").Each(func(i int, row *goquery.Selection) {
-			if i == 0 {
-				return // skip header
-			}
-
-			wg.Add(1)
-			go func(row *goquery.Selection) {
-				defer wg.Done()
-				element := parseElement(row)
-				if element != nil {
-					elementsChan <- *element
-				}
-			}(row)
-		})
-	})
-
-	// Wait for all goroutines to finish
-	go func() {
-		wg.Wait()
-		close(elementsChan)
-	}()
-
-	// Collect elements from the channel
-	elements := []Element{}
-	for element := range elementsChan {
-		elements = append(elements, element)
-	}
-
-	return elements
-}
-
-func saveElementsToFile(elements []Element, filePath string) {
-	log.Printf("Saving elements to file: %s", filePath)
-	start := time.Now()
-
-	// Ensure the directory exists
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		log.Fatalf("Failed to create directory: %v", err)
-	}
-
-	// Create the file
-	file, err := os.Create(filePath)
-	if err != nil {
-		log.Fatalf("Failed to create file: %v", err)
-	}
-	defer file.Close()
-
-	// Write JSON to the file
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(elements); err != nil {
-		log.Fatalf("Failed to write JSON: %v", err)
-	}
-
-	log.Printf("Elements saved to %s in %v", filePath, time.Since(start))
-}
-
-func getMissingElementsIngredients(elements []Element, url string) []Element {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("Failed to fetch page for second pass: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Fatalf("Status code error on second pass: %d %s", resp.StatusCode, resp.Status)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Fatalf("Failed to parse HTML: %v", err)
-	}
-
-	// 1. Collect all existing element names
-	existing := make(map[string]bool)
-	for _, e := range elements {
-		existing[e.Name] = true
-	}
-
-	// 2. Collect all ingredient names from recipes
-	used := make(map[string]bool)
-	for _, e := range elements {
-		for _, recipe := range e.Recipes {
-			for _, ing := range recipe {
-				used[ing] = true
-			}
-		}
-	}
-
-	// 3. Find missing ones
-	var missing []string
-	for ing := range used {
-		if !existing[ing] {
-			missing = append(missing, ing)
-		}
-	}
-	log.Printf("Found %d missing ingredients. Attempting to scrape them...", len(missing))
-
-	// 4. Try to scrape each missing element
-	for _, name := range missing {
-		row := findRowByElementName(doc, name)
-		if row != nil {
-			if el := parseElement(row); el != nil {
-				elements = append(elements, *el)
-			}
-		} else {
-			log.Printf("Could not find row for missing ingredient: %s", name)
-			// Optional: Add placeholder
-			imagePath := downloadImageFromIngredient(doc, name)
-			elements = append(elements, Element{Name: name, Recipes: [][]string{}, ImagePath: imagePath})
-
-		}
-	}
-
-	return elements
-}
-
-func findRowByElementName(doc *goquery.Document, name string) *goquery.Selection {
-	var result *goquery.Selection
-	doc.Find("table.list-table.col-list.icon-hover").Each(func(_ int, table *goquery.Selection) {
-		table.Find("tr").EachWithBreak(func(i int, row *goquery.Selection) bool {
-			text := row.Find("td").First().Text()
-			if strings.EqualFold(strings.TrimSpace(text), name) {
-				result = row
-				return false
-			}
-			return true
-		})
-	})
-	return result
-}
+package main
+
+import (
+	"alchemy-scraper/scraper"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const publicDir = "../backend/public"
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sprites" {
+		runSprites(os.Args[2:])
+		return
+	}
+
+	pageURL := flag.String("url", scraper.DefaultURL, "elements page to scrape")
+	input := flag.String("input", "", "read the page from a saved HTML file or a directory of saved pages instead of fetching it")
+	saveHTML := flag.String("save-html", "", "save the fetched page HTML to this file or directory")
+	output := flag.String("output", "../backend/data/elements.json", "where to write the dataset")
+	reportPath := flag.String("report", "", "where to write the run report, next to the dataset by default")
+	public := flag.String("public", publicDir, "directory the images are stored in")
+	concurrency := flag.Int("concurrency", 4, "maximum concurrent requests")
+	rate := flag.Float64("rate", 2, "maximum requests per second to a single host, 0 for no limit")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of a single request")
+	retries := flag.Int("retries", 3, "retries on 429, 5xx and network errors")
+	userAgent := flag.String("user-agent", "alchemy-scraper/1.0 (+https://github.com/yonatan-nyo/Tubes2_CCP)", "User-Agent header sent with every request")
+	deep := flag.Bool("deep", false, "also scrape each element's own wiki page for its description, used in count, pack and final flag")
+	aliasesPath := flag.String("aliases", "", "JSON file mapping alternative spellings to element names")
+	thumbSize := flag.Int("thumb-size", 32, "thumbnails fit in a square of this many pixels")
+	webp := flag.Bool("webp", false, "also write WebP copies of the images (needs cwebp on PATH)")
+	cacheDir := flag.String("cache-dir", ".cache", "directory for conditional request caching, empty to disable")
+	flag.Parse()
+
+	aliases, err := scraper.LoadAliases(*aliasesPath)
+	if err != nil {
+		log.Fatalf("Failed to load aliases: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dataset, report, err := scraper.Scrape(ctx, scraper.Options{
+		URL:       *pageURL,
+		Input:     *input,
+		SaveHTML:  *saveHTML,
+		PublicDir: *public,
+		Aliases:   aliases,
+		Deep:      *deep,
+		ThumbSize: *thumbSize,
+		WebP:      *webp,
+		Client: scraper.ClientOptions{
+			Concurrency: *concurrency,
+			RatePerHost: *rate,
+			Timeout:     *timeout,
+			Retries:     *retries,
+			UserAgent:   *userAgent,
+			CacheDir:    *cacheDir,
+		},
+	})
+
+	if *reportPath == "" {
+		*reportPath = strings.TrimSuffix(*output, filepath.Ext(*output)) + ".report.json"
+	}
+	if err := writeJSON(*reportPath, report); err != nil {
+		log.Printf("Failed to write report: %v", err)
+	}
+	report.WriteSummary(os.Stdout)
+
+	if err != nil {
+		log.Fatalf("Scrape failed: %v", err)
+	}
+	if err := writeJSON(*output, dataset); err != nil {
+		log.Fatalf("Failed to write dataset: %v", err)
+	}
+	log.Printf("Dataset saved to %s, report saved to %s", *output, *reportPath)
+}
+
+func runSprites(args []string) {
+	flags := flag.NewFlagSet("sprites", flag.ExitOnError)
+	dir := flags.String("public", publicDir, "directory with the element icons")
+	cellSize := flags.Int("cell", 40, "size in pixels of every icon in the sheet")
+	columns := flags.Int("columns", 32, "icons per row")
+	perSheet := flags.Int("per-sheet", 1024, "maximum icons in one sheet")
+	flags.Parse(args)
+
+	if err := scraper.BuildSprites(*dir, *cellSize, *columns, *perSheet); err != nil {
+		log.Fatalf("Failed to build sprite sheets: %v", err)
+	}
+}
+
+// writeJSON writes v indented to filePath, creating its directory.
+func writeJSON(filePath string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
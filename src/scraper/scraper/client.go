@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ClientOptions struct {
+	Concurrency int           // Maximum requests in flight
+	RatePerHost float64       // Requests per second to a single host, 0 is unlimited
+	Timeout     time.Duration // Timeout of a single attempt
+	Retries     int           // Retries after the first attempt on 429, 5xx and network errors
+	UserAgent   string
+	CacheDir    string // Responses with an ETag or Last-Modified are kept here, empty disables the cache
+}
+
+// Client is the HTTP client shared by every request of the scraper.
+type Client struct {
+	http    *http.Client
+	opts    ClientOptions
+	slots   chan struct{}
+	mu      sync.Mutex
+	nextReq map[string]time.Time // Earliest time the next request to a host may start
+}
+
+// cacheEntry is the metadata stored next to a cached response body.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func NewClient(opts ClientOptions) *Client {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	return &Client{
+		http:    &http.Client{Timeout: opts.Timeout},
+		opts:    opts,
+		slots:   make(chan struct{}, opts.Concurrency),
+		nextReq: map[string]time.Time{},
+	}
+}
+
+// Get returns the body of rawURL. A cached response is revalidated with
+// If-None-Match / If-Modified-Since and reused when the server answers 304.
+func (c *Client) Get(ctx context.Context, rawURL string) ([]byte, error) {
+	body, _, err := c.get(ctx, rawURL)
+	return body, err
+}
+
+// get is Get, and also reports whether the body is the cached one because
+// the server answered 304.
+func (c *Client) get(ctx context.Context, rawURL string) ([]byte, bool, error) {
+	cached, cachedBody := c.readCache(rawURL)
+
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.Retries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		if attempt > 0 {
+			log.Printf("Retrying %s (%d/%d): %v", rawURL, attempt, c.opts.Retries, lastErr)
+		}
+
+		resp, err := c.do(ctx, rawURL, cached)
+		if err != nil {
+			lastErr = err
+			c.waitToRetry(ctx, attempt, "")
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cachedBody != nil:
+			resp.Body.Close()
+			return cachedBody, true, nil
+		case resp.StatusCode == http.StatusOK:
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				lastErr = fmt.Errorf("failed to read response: %w", err)
+				c.waitToRetry(ctx, attempt, "")
+				continue
+			}
+			c.writeCache(rawURL, resp.Header, body)
+			return body, false, nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+			c.waitToRetry(ctx, attempt, resp.Header.Get("Retry-After"))
+		default:
+			resp.Body.Close()
+			return nil, false, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+		}
+	}
+	return nil, false, lastErr
+}
+
+func (c *Client) do(ctx context.Context, rawURL string, cached *cacheEntry) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.slots }()
+	if err := c.waitForHost(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	return c.http.Do(req)
+}
+
+// waitForHost spaces requests to the same host by 1/RatePerHost seconds. It
+// returns early with ctx's error when ctx is done.
+func (c *Client) waitForHost(ctx context.Context, host string) error {
+	if c.opts.RatePerHost <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / c.opts.RatePerHost)
+
+	c.mu.Lock()
+	now := time.Now()
+	start := c.nextReq[host]
+	if start.Before(now) {
+		start = now
+	}
+	c.nextReq[host] = start.Add(interval)
+	c.mu.Unlock()
+
+	return sleep(ctx, time.Until(start))
+}
+
+// waitToRetry waits before the attempt after attempt, there is no wait once
+// the last attempt failed.
+func (c *Client) waitToRetry(ctx context.Context, attempt int, retryAfter string) {
+	if attempt < c.opts.Retries {
+		sleep(ctx, backoff(attempt, retryAfter))
+	}
+}
+
+// sleep waits for d, or until ctx is done and returns its error.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff waits 500ms, 1s, 2s, ... between attempts, or what the server asked
+// for in Retry-After.
+func backoff(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 500 * time.Millisecond << attempt
+}
+
+func (c *Client) cachePath(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(c.opts.CacheDir, hex.EncodeToString(sum[:]))
+}
+
+func (c *Client) readCache(rawURL string) (*cacheEntry, []byte) {
+	if c.opts.CacheDir == "" {
+		return nil, nil
+	}
+	path := c.cachePath(rawURL)
+	meta, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.URL != rawURL {
+		return nil, nil
+	}
+	body, err := os.ReadFile(path + ".body")
+	if err != nil {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+func (c *Client) writeCache(rawURL string, header http.Header, body []byte) {
+	entry := cacheEntry{URL: rawURL, ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+	if c.opts.CacheDir == "" || (entry.ETag == "" && entry.LastModified == "") {
+		return
+	}
+	if err := os.MkdirAll(c.opts.CacheDir, os.ModePerm); err != nil {
+		log.Printf("Failed to create cache directory: %v", err)
+		return
+	}
+
+	meta, _ := json.Marshal(entry)
+	path := c.cachePath(rawURL)
+	if err := os.WriteFile(path+".body", body, 0644); err != nil {
+		log.Printf("Failed to cache %s: %v", rawURL, err)
+		return
+	}
+	if err := os.WriteFile(path+".json", meta, 0644); err != nil {
+		log.Printf("Failed to cache %s: %v", rawURL, err)
+	}
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Keep in sync with the backend (models/dataset.go and data/elements.schema.json)
+const DatasetSchemaVersion = 1
+
+type Dataset struct {
+	SchemaVersion  int               `json:"schema_version"`
+	Name           string            `json:"dataset"`
+	Source         string            `json:"source,omitempty"`
+	ScrapedAt      *time.Time        `json:"scraped_at,omitempty"`
+	BaseElements   []string          `json:"base_elements,omitempty"`
+	ImageChecksums map[string]string `json:"image_checksums,omitempty"`
+	Aliases        map[string]string `json:"aliases,omitempty"`
+	Elements       []Element         `json:"elements"`
+}
+
+func (r *run) newDataset(elements []Element) Dataset {
+	scrapedAt := time.Now().UTC()
+	dataset := Dataset{
+		SchemaVersion:  DatasetSchemaVersion,
+		Name:           "little-alchemy-2",
+		Source:         r.opts.URL,
+		ScrapedAt:      &scrapedAt,
+		BaseElements:   []string{"Air", "Earth", "Fire", "Water"},
+		ImageChecksums: map[string]string{},
+		Aliases:        datasetAliases(elements, r.opts.Aliases),
+		Elements:       make([]Element, 0, len(elements)),
+	}
+
+	for _, el := range elements {
+		// The backend only accepts recipes with exactly two ingredients
+		recipes := make([][]string, 0, len(el.Recipes))
+		for _, recipe := range el.Recipes {
+			if len(recipe) != 2 {
+				log.Printf("Skipping recipe of %s with %d ingredients: %v", el.Name, len(recipe), recipe)
+				continue
+			}
+			recipes = append(recipes, recipe)
+		}
+		el.Recipes = recipes
+		dataset.Elements = append(dataset.Elements, el)
+
+		if el.ImagePath == "" {
+			continue
+		}
+		if _, ok := dataset.ImageChecksums[el.ImagePath]; ok {
+			continue
+		}
+		checksum, err := r.imageChecksum(el.ImagePath)
+		if err != nil {
+			log.Printf("Failed to checksum image for %s: %v", el.Name, err)
+			continue
+		}
+		dataset.ImageChecksums[el.ImagePath] = checksum
+	}
+
+	return dataset
+}
+
+// datasetAliases keeps the aliases that refer to a scraped element, spelled
+// like that element, so the backend can look elements up by them too.
+func datasetAliases(elements []Element, aliases map[string]string) map[string]string {
+	names := map[string]string{}
+	for _, el := range elements {
+		names[strings.ToLower(el.Name)] = el.Name
+	}
+
+	kept := map[string]string{}
+	for alias, name := range aliases {
+		alias = normalizeName(alias)
+		name, ok := names[strings.ToLower(normalizeName(name))]
+		if !ok || alias == "" || strings.EqualFold(alias, name) {
+			continue
+		}
+		kept[alias] = name
+	}
+	return kept
+}
+
+// imageChecksum hashes the file behind an image path.
+func (r *run) imageChecksum(imagePath string) (string, error) {
+	filePath, err := r.imageFile(imagePath)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
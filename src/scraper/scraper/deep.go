@@ -0,0 +1,141 @@
+package scraper
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	packBaseGame         = "Base game"
+	packMythsAndMonsters = "Myths and Monsters"
+)
+
+var finalElementPattern = regexp.MustCompile(`(?i)\bfinal element\b`)
+
+// deepScrapeElements visits the wiki page of every element and fills in its
+// description, used in count, pack and whether it is a final element. Pages
+// are read from and saved to the same places as the elements page, so an
+// offline run needs the element pages saved next to it.
+func (r *run) deepScrapeElements(elements []Element) {
+	inputDir := pageDir(r.opts.Input)
+	saveDir := pageDir(r.opts.SaveHTML)
+
+	var wg sync.WaitGroup
+	jobs := make(chan *Element)
+	for range r.client.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for el := range jobs {
+				if r.ctx.Err() != nil {
+					continue
+				}
+				elementURL := elementPageURL(r.opts.URL, el)
+				doc, err := r.loadPage(elementURL, inputDir, saveDir)
+				if err != nil {
+					log.Printf("Failed to load page of %s: %v", el.Name, err)
+					continue
+				}
+				parseElementPage(doc, el)
+			}
+		}()
+	}
+
+	for i := range elements {
+		jobs <- &elements[i]
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// pageDir turns an Input or SaveHTML path into the directory holding the
+// element pages, which is the path itself or the directory of a single file.
+func pageDir(path string) string {
+	if path == "" {
+		return ""
+	}
+	if info, err := os.Stat(path); (err == nil && info.IsDir()) || strings.HasSuffix(path, string(os.PathSeparator)) {
+		return path
+	}
+	return filepath.Dir(path) + string(os.PathSeparator)
+}
+
+func elementPageURL(pageURL string, el *Element) string {
+	link := el.pageLink
+	if link == "" {
+		link = "/wiki/" + url.PathEscape(strings.ReplaceAll(el.Name, " ", "_"))
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func parseElementPage(doc *goquery.Document, el *Element) {
+	content := doc.Find(".mw-parser-output").First()
+	if content.Length() == 0 {
+		content = doc.Selection
+	}
+
+	// Description from the infobox, otherwise the first paragraph of the article
+	el.Description = cleanText(content.Find(`.portable-infobox [data-source="description"] .pi-data-value`).First().Text())
+	if el.Description == "" {
+		content.ChildrenFiltered("p").EachWithBreak(func(_ int, p *goquery.Selection) bool {
+			el.Description = cleanText(p.Text())
+			return el.Description == ""
+		})
+	}
+
+	el.UsedIn = countUsedIn(content)
+
+	categories := strings.ToLower(doc.Find(".page-header__categories a, #articleCategories a, .categories a").Text())
+	infoboxPack := cleanText(content.Find(`.portable-infobox [data-source="pack"] .pi-data-value`).Text())
+	el.Pack = packBaseGame
+	if strings.Contains(strings.ToLower(infoboxPack), "myths") || strings.Contains(categories, "myths and monsters") {
+		el.Pack = packMythsAndMonsters
+	}
+
+	infobox := content.Find(".portable-infobox").Text()
+	el.IsFinal = strings.Contains(categories, "final element") ||
+		finalElementPattern.MatchString(el.Description) || finalElementPattern.MatchString(infobox)
+}
+
+// countUsedIn counts the list items under the "Used in" heading, up to the
+// next heading of the same or a higher level.
+func countUsedIn(content *goquery.Selection) int {
+	count := 0
+	content.Find("h2, h3, h4").EachWithBreak(func(_ int, heading *goquery.Selection) bool {
+		if !strings.HasPrefix(strings.ToLower(cleanText(heading.Text())), "used in") {
+			return true
+		}
+		level := goquery.NodeName(heading)
+		for sibling := heading.Next(); sibling.Length() > 0; sibling = sibling.Next() {
+			name := goquery.NodeName(sibling)
+			if strings.HasPrefix(name, "h") && len(name) == 2 && name <= level {
+				break
+			}
+			count += sibling.Find("li").Length()
+			if name == "li" {
+				count++
+			}
+		}
+		return false
+	})
+	return count
+}
+
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type Element struct {
+	Name      string     `json:"name"`
+	Recipes   [][]string `json:"recipes"`
+	ImagePath string     `json:"image_path"`
+
+	// Filled in by the deep scrape (Options.Deep) from the element's own page
+	Description string `json:"description,omitempty"`
+	UsedIn      int    `json:"used_in,omitempty"`
+	Pack        string `json:"pack,omitempty"`
+	IsFinal     bool   `json:"is_final,omitempty"`
+
+	// Filled in by processImages
+	ThumbPath   string `json:"thumb_path,omitempty"`
+	WebPPath    string `json:"webp_path,omitempty"`
+	ImageWidth  int    `json:"image_width,omitempty"`
+	ImageHeight int    `json:"image_height,omitempty"`
+	ImageHash   string `json:"image_hash,omitempty"` // SHA-256 of the PNG file
+
+	pageLink string // href of the element's wiki page in the elements table
+}
+
+func (r *run) parseElement(row *goquery.Selection) *Element {
+	cells := row.Find("td")
+	if cells.Length() != 2 {
+		return nil
+	}
+
+	elementName := normalizeName(cells.Eq(0).Text())
+	if elementName == "" || strings.ToLower(elementName) == "element" {
+		return nil
+	}
+
+	recipes := r.parseRecipes(cells.Eq(1), elementName)
+	imagePath := r.downloadImage(cells.Eq(0), elementName)
+	pageLink := elementPageLink(cells.Eq(0), elementName)
+
+	return &Element{
+		Name:      elementName,
+		Recipes:   recipes,
+		ImagePath: imagePath,
+		pageLink:  pageLink,
+	}
+}
+
+// elementPageLink is the href of the link named after the element, which
+// points to the element's own wiki page.
+func elementPageLink(cell *goquery.Selection, elementName string) string {
+	link := ""
+	cell.Find("a[href]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+		if strings.EqualFold(normalizeName(a.Text()), elementName) {
+			link, _ = a.Attr("href")
+			return false
+		}
+		return true
+	})
+	return link
+}
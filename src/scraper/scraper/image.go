@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func (r *run) downloadImage(cell *goquery.Selection, elementName string) string {
+	imagePath := ""
+
+	// Check for image source in the `img` tag
+	cell.Find("img").Each(func(_ int, img *goquery.Selection) {
+		if src, exists := imageSource(img); exists {
+			// Encode the element name for the image file
+			imagePath = r.saveImage(src, elementName, strings.ReplaceAll(elementName, " ", "_")+".png")
+		}
+	})
+
+	return imagePath
+}
+
+func (r *run) downloadImageFromIngredient(doc *goquery.Document, ingredientName string) string {
+	var imagePath string
+
+	// Find the table containing ingredient rows
+	doc.Find("table.list-table.col-list.icon-hover").Each(func(_ int, table *goquery.Selection) {
+		table.Find("td").Each(func(_ int, cell *goquery.Selection) {
+			cell.Find("a").Each(func(_ int, a *goquery.Selection) {
+				if strings.EqualFold(strings.TrimSpace(a.Text()), ingredientName) {
+					img := a.Find("img")
+					if img.Length() == 0 {
+						img = a.Parent().Find("img")
+					}
+					if src, exists := imageSource(img); img.Length() > 0 && exists {
+						// Use raw (not escaped) name to save
+						imagePath = r.saveImage(src, ingredientName, strings.ReplaceAll(ingredientName, " ", "_")+".png")
+					}
+				}
+			})
+		})
+	})
+
+	return imagePath
+}
+
+// imageSource is the URL of img, lazy loaded images keep it in data-src.
+func imageSource(img *goquery.Selection) (string, bool) {
+	src, exists := img.Attr("data-src")
+	if !exists {
+		src, exists = img.Attr("src")
+	}
+	return src, exists
+}
+
+// saveImage downloads the image of elementName from src to filename in the
+// public directory and returns filename, or "" when there is no image. An
+// image already on disk goes through the client's conditional cache too and
+// is only rewritten when the server has a newer one.
+func (r *run) saveImage(src string, elementName string, filename string) string {
+	filePath := filepath.Join(r.opts.PublicDir, filename)
+
+	// Ensure the directory exists
+	if err := os.MkdirAll(r.opts.PublicDir, os.ModePerm); err != nil {
+		log.Printf("Failed to create directories for %s: %v", elementName, err)
+		return ""
+	}
+
+	// The image on disk is kept whenever no newer one can be saved
+	existing := ""
+	if _, err := os.Stat(filePath); err == nil {
+		existing = filename
+	}
+
+	if r.opts.Input != "" {
+		if existing == "" {
+			log.Printf("Image for %s is not downloaded yet, skipping it in offline mode", elementName)
+		}
+		r.countImage(imageSkipped)
+		return existing
+	}
+
+	log.Printf("\nDownloading image for %s", elementName)
+	start := time.Now()
+
+	data, notModified, err := r.client.get(r.ctx, src)
+	if err != nil {
+		log.Printf("Failed to download image for %s: %v", elementName, err)
+		r.countImage(imageFailed)
+		return existing
+	}
+	if notModified && existing != "" {
+		log.Printf("Image for %s is unchanged at %s, skipping it", elementName, filePath)
+		r.countImage(imageSkipped)
+		return existing
+	}
+	data, err = normalizeImage(data)
+	if err != nil {
+		log.Printf("Downloaded image for %s is rejected: %v", elementName, err)
+		r.countImage(imageFailed)
+		return existing
+	}
+
+	// Save the image content to the file
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		log.Printf("Failed to save image for %s: %v", elementName, err)
+		r.countImage(imageFailed)
+		return existing
+	}
+
+	log.Printf("Image for %s downloaded and saved to %s in %v", elementName, filePath, time.Since(start))
+
+	// Return the asset key of the image
+	r.countImage(imageDownloaded)
+	return filename
+}
@@ -0,0 +1,159 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Image paths in the dataset are asset keys, the path of the file relative
+// to the backend's public directory with forward slashes, e.g. "Fire.png" or
+// "thumbs/Fire.png". The backend serves them under /assets/.
+
+// imageFile returns the file of an asset key.
+func (r *run) imageFile(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid asset key: %s", key)
+	}
+	return filepath.Join(r.opts.PublicDir, filepath.FromSlash(key)), nil
+}
+
+// normalizeImage checks that data decodes as an image and re-encodes it as
+// PNG when it is in another format, the wiki serves some icons as WebP or JPEG.
+func normalizeImage(data []byte) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid image: %w", err)
+	}
+	if format == "png" {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to PNG: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// processImages validates every element's image, normalizes it to PNG,
+// records its size and hash and writes the thumbnail (and WebP copy). Images
+// that do not decode are deleted so the next run downloads them again.
+func (r *run) processImages(elements []Element) {
+	for i := range elements {
+		el := &elements[i]
+		if el.ImagePath == "" {
+			continue
+		}
+		if err := r.processImage(el); err != nil {
+			log.Printf("Dropping image of %s: %v", el.Name, err)
+			r.countImage(imageFailed)
+			if filePath, err := r.imageFile(el.ImagePath); err == nil {
+				os.Remove(filePath)
+			}
+			el.ImagePath = ""
+		}
+	}
+}
+
+func (r *run) processImage(el *Element) error {
+	filePath, err := r.imageFile(el.ImagePath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := normalizeImage(data)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(normalized, data) {
+		if err := os.WriteFile(filePath, normalized, 0644); err != nil {
+			return err
+		}
+	}
+
+	img, err := png.Decode(bytes.NewReader(normalized))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(normalized)
+	el.ImageWidth = img.Bounds().Dx()
+	el.ImageHeight = img.Bounds().Dy()
+	el.ImageHash = hex.EncodeToString(sum[:])
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	thumbPath := filepath.Join(r.opts.PublicDir, "thumbs", name+".png")
+	if err := writeThumbnail(img, thumbPath, r.opts.ThumbSize); err != nil {
+		return fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	el.ThumbPath = "thumbs/" + name + ".png"
+
+	if r.opts.WebP {
+		webpPath := filepath.Join(r.opts.PublicDir, "webp", name+".webp")
+		if err := writeWebP(filePath, webpPath); err != nil {
+			log.Printf("Failed to write WebP image of %s: %v", el.Name, err)
+		} else {
+			el.WebPPath = "webp/" + name + ".webp"
+		}
+	}
+	return nil
+}
+
+// writeThumbnail scales img down to fit in the thumbnail box, keeping its
+// aspect ratio. Images already smaller than the box are copied as they are.
+func writeThumbnail(img image.Image, thumbPath string, thumbSize int) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbSize || height > thumbSize {
+		if width >= height {
+			width, height = thumbSize, max(1, height*thumbSize/width)
+		} else {
+			width, height = max(1, width*thumbSize/height), thumbSize
+		}
+	}
+
+	thumb := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumb); err != nil {
+		return err
+	}
+	if existing, err := os.ReadFile(thumbPath); err == nil && bytes.Equal(existing, buf.Bytes()) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(thumbPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(thumbPath, buf.Bytes(), 0644)
+}
+
+// writeWebP converts the PNG at pngPath with cwebp, the Go image libraries
+// can only decode WebP.
+func writeWebP(pngPath string, webpPath string) error {
+	if err := os.MkdirAll(filepath.Dir(webpPath), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := exec.Command("cwebp", "-quiet", "-q", "80", pngPath, "-o", webpPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cwebp: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// loadPage returns the parsed elements page. The HTML is read from input
+// when it is set (a saved page, or a directory of pages named by
+// pageFileName), otherwise it is fetched from pageURL. When saveHTML is set
+// the raw HTML is also written there so later runs can use it as input.
+func (r *run) loadPage(pageURL string, input string, saveHTML string) (*goquery.Document, error) {
+	var (
+		html []byte
+		err  error
+	)
+	if input != "" {
+		html, err = readPage(pageURL, input)
+	} else {
+		html, err = r.fetchPage(pageURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if saveHTML != "" {
+		if err := savePage(pageURL, saveHTML, html); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return doc, nil
+}
+
+func (r *run) fetchPage(pageURL string) ([]byte, error) {
+	log.Printf("Fetching %s", pageURL)
+	html, err := r.client.Get(r.ctx, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	return html, nil
+}
+
+func readPage(pageURL string, input string) ([]byte, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		input = filepath.Join(input, pageFileName(pageURL))
+	}
+
+	log.Printf("Reading page from %s", input)
+	return os.ReadFile(input)
+}
+
+func savePage(pageURL string, saveHTML string, html []byte) error {
+	// An existing directory or a path ending in a separator is a page cache
+	if info, err := os.Stat(saveHTML); (err == nil && info.IsDir()) || strings.HasSuffix(saveHTML, string(os.PathSeparator)) {
+		saveHTML = filepath.Join(saveHTML, pageFileName(pageURL))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(saveHTML), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(saveHTML, html, 0644); err != nil {
+		return fmt.Errorf("failed to save HTML: %w", err)
+	}
+	log.Printf("Saved page HTML to %s", saveHTML)
+	return nil
+}
+
+// pageFileName is the name of a page inside a cache directory, the last
+// segment of its URL, e.g. Elements_(Little_Alchemy_2).html.
+func pageFileName(pageURL string) string {
+	name := "page"
+	if u, err := url.Parse(pageURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	return name + ".html"
+}
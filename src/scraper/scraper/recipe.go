@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"encoding/json"
+	"html"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	// Notes the wiki puts after names: the pack, "(Myths and Monsters)", and
+	// footnotes like "[1]". Other brackets can be part of an element's name.
+	annotationSuffixPattern = regexp.MustCompile(`(?i)\s*(\(myths and monsters\)|\(base game\)|\[[^\]]*\])\s*$`)
+	// Entries that describe how an element is obtained instead of a recipe
+	annotationPattern = regexp.MustCompile(`(?i)available from the start|starting element|cannot be (made|created)|no recipes?`)
+)
+
+// parseRecipes reads the recipe cell of an element row, one recipe per <li>
+// written as "Ingredient + Ingredient". Entries that are only an annotation
+// are skipped and entries without exactly two ingredients are reported.
+func (r *run) parseRecipes(cell *goquery.Selection, elementName string) [][]string {
+	recipes := [][]string{}
+	cell.Find("li").Each(func(_ int, li *goquery.Selection) {
+		entry := cleanText(li.Text())
+		if entry == "" || annotationPattern.MatchString(entry) {
+			return
+		}
+
+		ingredients := splitRecipeText(entry)
+		if len(ingredients) != 2 {
+			ingredients = recipeLinks(li)
+		}
+		if len(ingredients) != 2 {
+			r.addParseProblem(elementName, entry, "expected 2 ingredients")
+			return
+		}
+		recipes = append(recipes, ingredients)
+	})
+
+	return recipes
+}
+
+// splitRecipeText splits "A + B" on the plus sign.
+func splitRecipeText(entry string) []string {
+	ingredients := []string{}
+	for _, part := range strings.Split(entry, "+") {
+		if name := normalizeName(part); name != "" {
+			ingredients = append(ingredients, name)
+		}
+	}
+	return ingredients
+}
+
+// recipeLinks reads the ingredients from the links of an entry. Icon links
+// have no text. A name linked twice inside the same cell (icon caption and
+// label) counts once, but two links straight in the entry are two
+// ingredients, so "Stone Stone" stays a recipe.
+func recipeLinks(li *goquery.Selection) []string {
+	ingredients := []string{}
+	var lastCell *goquery.Selection
+	li.Find("a").Each(func(_ int, a *goquery.Selection) {
+		if href, _ := a.Attr("href"); strings.Contains(href, "File:") {
+			return
+		}
+		name := normalizeName(a.Text())
+		if name == "" || strings.EqualFold(name, "file") {
+			return
+		}
+		cell := a.Parent()
+		if cell.IsSelection(li) {
+			cell = a
+		}
+		if lastCell != nil && cell.IsSelection(lastCell) && ingredients[len(ingredients)-1] == name {
+			return
+		}
+		lastCell = cell
+		ingredients = append(ingredients, name)
+	})
+	return ingredients
+}
+
+// normalizeName decodes entities, drops the notes the wiki puts after names
+// and collapses whitespace, so "Fire&nbsp;(Myths and Monsters)" becomes "Fire".
+func normalizeName(name string) string {
+	name = cleanText(html.UnescapeString(name))
+	for {
+		stripped := annotationSuffixPattern.ReplaceAllString(name, "")
+		if stripped == name {
+			break
+		}
+		name = stripped
+	}
+	name = strings.Trim(name, "*,.")
+	return strings.TrimSpace(name)
+}
+
+// LoadAliases reads a JSON object mapping alternative spellings to element names.
+func LoadAliases(filePath string) (map[string]string, error) {
+	aliases := map[string]string{}
+	if filePath == "" {
+		return aliases, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// canonicalizeNames rewrites every ingredient to the spelling of the element
+// it refers to, resolving aliases first and then matching case-insensitively.
+// Recipes that become duplicates, in either order, are dropped.
+func canonicalizeNames(elements []Element, aliases map[string]string) {
+	canonical := map[string]string{}
+	for _, el := range elements {
+		canonical[strings.ToLower(el.Name)] = el.Name
+	}
+	for alias, name := range aliases {
+		if target, ok := canonical[strings.ToLower(name)]; ok {
+			name = target
+		}
+		canonical[strings.ToLower(normalizeName(alias))] = name
+	}
+
+	resolve := func(name string) string {
+		if target, ok := canonical[strings.ToLower(name)]; ok {
+			return target
+		}
+		return name
+	}
+
+	for i := range elements {
+		seen := map[string]bool{}
+		recipes := make([][]string, 0, len(elements[i].Recipes))
+		for _, recipe := range elements[i].Recipes {
+			resolved := make([]string, len(recipe))
+			for j, ingredient := range recipe {
+				resolved[j] = resolve(ingredient)
+			}
+			sorted := slices.Clone(resolved)
+			slices.Sort(sorted)
+			key := strings.Join(sorted, "\x00")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			recipes = append(recipes, resolved)
+		}
+		elements[i].Recipes = recipes
+	}
+}
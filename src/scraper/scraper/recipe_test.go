@@ -0,0 +1,164 @@
+package scraper
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// loadRecipeRows parses testdata/recipes.html, a cut down copy of the wiki's
+// element table, into its rows by element name.
+func loadRecipeRows(t *testing.T) map[string]*goquery.Selection {
+	t.Helper()
+	file, err := os.Open("testdata/recipes.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := map[string]*goquery.Selection{}
+	doc.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() == 2 {
+			rows[normalizeName(cells.Eq(0).Text())] = cells.Eq(1)
+		}
+	})
+	return rows
+}
+
+func TestParseRecipes(t *testing.T) {
+	rows := loadRecipeRows(t)
+
+	tests := []struct {
+		name     string
+		element  string
+		recipes  [][]string
+		problems []ParseProblem
+	}{
+		{
+			name:    "available from the start",
+			element: "Air",
+			recipes: [][]string{},
+		},
+		{
+			name:    "plain rows",
+			element: "Steam",
+			recipes: [][]string{{"Air", "Fire"}, {"Water", "Fire"}, {"Fire", "Water"}},
+		},
+		{
+			name:    "icon and label links",
+			element: "Lava",
+			recipes: [][]string{{"Earth", "Fire"}, {"Earth", "Heat"}},
+		},
+		{
+			name:    "same ingredient twice",
+			element: "Boulder",
+			recipes: [][]string{{"Stone", "Stone"}},
+		},
+		{
+			name:    "entities, whitespace and notes",
+			element: "Mud",
+			recipes: [][]string{{"water", "Earth"}, {"Soil", "Rain"}, {"Dirt", "Water"}},
+		},
+		{
+			name:    "brackets in names",
+			element: "Cat (animal)",
+			recipes: [][]string{{"Animal", "Milk & Cookies (dessert)"}},
+		},
+		{
+			name:    "unparseable entries",
+			element: "Storm",
+			recipes: [][]string{},
+			problems: []ParseProblem{
+				{Element: "Storm", Entry: "Cloud, Wind and Energy", Reason: "expected 2 ingredients"},
+				{Element: "Storm", Entry: "Cloud +", Reason: "expected 2 ingredients"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell, ok := rows[tt.element]
+			if !ok {
+				t.Fatalf("no row for %q in the fixture", tt.element)
+			}
+			r := &run{report: newReport("test")}
+			recipes := r.parseRecipes(cell, tt.element)
+			if !reflect.DeepEqual(recipes, tt.recipes) {
+				t.Errorf("recipes = %q, want %q", recipes, tt.recipes)
+			}
+			if problems := r.report.ParseProblems; (len(problems) > 0 || len(tt.problems) > 0) && !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("parse problems = %+v, want %+v", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"  Fire\n ", "Fire"},
+		{"Fire&nbsp;", "Fire"},
+		{"Milk &amp; Cookies", "Milk & Cookies"},
+		{"Fire (Myths and Monsters)", "Fire"},
+		{"Fire [note 2] (Myths and Monsters)", "Fire"},
+		{"Cat (animal)", "Cat (animal)"},
+		{"Energy*", "Energy"},
+		{"(Myths and Monsters)", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeName(tt.name); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipes [][]string
+		aliases map[string]string
+		want    [][]string
+	}{
+		{
+			name:    "case follows the element",
+			recipes: [][]string{{"water", "EARTH"}},
+			want:    [][]string{{"Water", "Earth"}},
+		},
+		{
+			name:    "reversed pair is a duplicate",
+			recipes: [][]string{{"Water", "Fire"}, {"Fire", "Water"}, {"fire", "water"}},
+			want:    [][]string{{"Water", "Fire"}},
+		},
+		{
+			name:    "aliases",
+			recipes: [][]string{{"Dirt", "Water"}, {"Water", "Earth"}, {"soil", "Fire"}},
+			aliases: map[string]string{"dirt": "earth", "Soil (Myths and Monsters)": "Earth"},
+			want:    [][]string{{"Earth", "Water"}, {"Earth", "Fire"}},
+		},
+		{
+			name:    "same ingredient twice",
+			recipes: [][]string{{"Fire", "Fire"}, {"fire", "FIRE"}},
+			want:    [][]string{{"Fire", "Fire"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements := []Element{{Name: "Water"}, {Name: "Earth"}, {Name: "Fire"}, {Name: "Steam", Recipes: tt.recipes}}
+			canonicalizeNames(elements, tt.aliases)
+			if got := elements[3].Recipes; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recipes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
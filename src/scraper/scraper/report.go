@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report describes one scrape run. It is written as JSON next to the dataset.
+type Report struct {
+	Source    string    `json:"source"`
+	StartedAt time.Time `json:"started_at"`
+
+	Elements int `json:"elements"`
+	Recipes  int `json:"recipes"`
+
+	// Ingredients that had no row of their own in the first pass
+	MissingIngredients []string `json:"missing_ingredients"`
+	// Missing ingredients whose row was found in the second pass
+	RecoveredIngredients []string `json:"recovered_ingredients"`
+	// Missing ingredients added without recipes because no row was found
+	PlaceholderElements []string `json:"placeholder_elements"`
+
+	Images ImageCounts `json:"images"`
+
+	// Recipe entries that could not be parsed into two ingredients
+	ParseProblems []ParseProblem `json:"parse_problems"`
+
+	Durations Durations `json:"durations"`
+}
+
+type ImageCounts struct {
+	Downloaded int `json:"downloaded"`
+	Skipped    int `json:"skipped"` // Unchanged since the last download, or not downloaded in offline mode
+	Failed     int `json:"failed"`  // Download failed or the file is not an image
+}
+
+// Durations of each phase of the run, in milliseconds.
+type Durations struct {
+	LoadPage int64 `json:"load_page_ms"`
+	Parse    int64 `json:"parse_ms"`
+	Missing  int64 `json:"missing_ms"`
+	Deep     int64 `json:"deep_ms"`
+	Images   int64 `json:"images_ms"`
+	Total    int64 `json:"total_ms"`
+}
+
+// ParseProblem is a recipe entry the parser could not turn into two ingredients.
+type ParseProblem struct {
+	Element string `json:"element"`
+	Entry   string `json:"entry"`
+	Reason  string `json:"reason"`
+}
+
+func newReport(source string) *Report {
+	return &Report{
+		Source:               source,
+		StartedAt:            time.Now().UTC(),
+		MissingIngredients:   []string{},
+		RecoveredIngredients: []string{},
+		PlaceholderElements:  []string{},
+		ParseProblems:        []ParseProblem{},
+	}
+}
+
+// WriteSummary prints the report as a table.
+func (r *Report) WriteSummary(w io.Writer) error {
+	problems := append([]ParseProblem(nil), r.ParseProblems...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Element < problems[j].Element
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"Elements", fmt.Sprint(r.Elements)},
+		{"Recipes", fmt.Sprint(r.Recipes)},
+		{"Missing ingredients", fmt.Sprint(len(r.MissingIngredients))},
+		{"  recovered", list(r.RecoveredIngredients)},
+		{"  placeholders", list(r.PlaceholderElements)},
+		{"Images downloaded", fmt.Sprint(r.Images.Downloaded)},
+		{"Images skipped", fmt.Sprint(r.Images.Skipped)},
+		{"Images failed", fmt.Sprint(r.Images.Failed)},
+		{"Unparseable entries", fmt.Sprint(len(problems))},
+		{"Load page", ms(r.Durations.LoadPage)},
+		{"Parse", ms(r.Durations.Parse)},
+		{"Missing ingredient pass", ms(r.Durations.Missing)},
+		{"Deep scrape", ms(r.Durations.Deep)},
+		{"Images", ms(r.Durations.Images)},
+		{"Total", ms(r.Durations.Total)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+func list(names []string) string {
+	if len(names) == 0 {
+		return "0"
+	}
+	if len(names) > 10 {
+		return fmt.Sprintf("%d (%s, ...)", len(names), strings.Join(names[:10], ", "))
+	}
+	return fmt.Sprintf("%d (%s)", len(names), strings.Join(names, ", "))
+}
+
+func ms(duration int64) string {
+	return (time.Duration(duration) * time.Millisecond).String()
+}
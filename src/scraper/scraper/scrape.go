@@ -0,0 +1,246 @@
+// Package scraper builds the Little Alchemy 2 dataset from the fandom wiki:
+// the elements table, the icons and optionally every element's own page.
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const DefaultURL = "https://little-alchemy.fandom.com/wiki/Elements_(Little_Alchemy_2)"
+
+type Options struct {
+	URL string // Elements page, DefaultURL when empty
+
+	// Read the pages from a saved HTML file or a directory of saved pages
+	// instead of fetching them. Images that are not downloaded yet are skipped.
+	Input string
+	// Save the fetched HTML to this file or directory
+	SaveHTML string
+
+	PublicDir string            // Where images are stored, the backend's public directory
+	Aliases   map[string]string // Alternative spellings to element names
+	Deep      bool              // Also scrape every element's own page
+	ThumbSize int               // Thumbnails fit in a ThumbSize x ThumbSize box
+	WebP      bool              // Also write WebP copies of the images, needs cwebp on PATH
+
+	Client ClientOptions
+}
+
+// run holds the state of one Scrape call.
+type run struct {
+	ctx    context.Context
+	opts   Options
+	client *Client
+
+	mu     sync.Mutex // Guards report, rows are parsed concurrently
+	report *Report
+}
+
+// Scrape builds the dataset described by opts. The report is returned with
+// whatever was done so far when the scrape fails.
+func Scrape(ctx context.Context, opts Options) (Dataset, Report, error) {
+	if opts.URL == "" {
+		opts.URL = DefaultURL
+	}
+	if opts.PublicDir == "" {
+		return Dataset{}, Report{}, fmt.Errorf("public directory for images must be set")
+	}
+	if opts.ThumbSize < 1 {
+		opts.ThumbSize = 32
+	}
+	r := &run{
+		ctx:    ctx,
+		opts:   opts,
+		client: NewClient(opts.Client),
+		report: newReport(opts.URL),
+	}
+	start := time.Now()
+	defer func() {
+		r.report.Durations.Total = time.Since(start).Milliseconds()
+	}()
+
+	phase := time.Now()
+	doc, err := r.loadPage(opts.URL, opts.Input, opts.SaveHTML)
+	r.report.Durations.LoadPage = time.Since(phase).Milliseconds()
+	if err != nil {
+		return Dataset{}, *r.report, err
+	}
+
+	phase = time.Now()
+	elements := r.scrapeElements(doc)
+	canonicalizeNames(elements, opts.Aliases)
+	r.report.Durations.Parse = time.Since(phase).Milliseconds()
+	log.Printf("Found %d elements in the table", len(elements))
+
+	phase = time.Now()
+	elements = r.getMissingElementsIngredients(elements, doc)
+	r.report.Durations.Missing = time.Since(phase).Milliseconds()
+	if err := ctx.Err(); err != nil {
+		return Dataset{}, *r.report, err
+	}
+
+	if opts.Deep {
+		phase = time.Now()
+		r.deepScrapeElements(elements)
+		r.report.Durations.Deep = time.Since(phase).Milliseconds()
+		if err := ctx.Err(); err != nil {
+			return Dataset{}, *r.report, err
+		}
+	}
+
+	phase = time.Now()
+	r.processImages(elements)
+	r.report.Durations.Images = time.Since(phase).Milliseconds()
+
+	dataset := r.newDataset(elements)
+	r.report.Elements = len(dataset.Elements)
+	for _, el := range dataset.Elements {
+		r.report.Recipes += len(el.Recipes)
+	}
+	r.report.Durations.Total = time.Since(start).Milliseconds()
+	return dataset, *r.report, nil
+}
+
+func (r *run) scrapeElements(doc *goquery.Document) []Element {
+	rows := []*goquery.Selection{}
+	doc.Find("table.list-table.col-list.icon-hover").Each(func(_ int, table *goquery.Selection) {
+		table.Find("tr").Each(func(i int, row *goquery.Selection) {
+			if i == 0 {
+				return // skip header
+			}
+			rows = append(rows, row)
+		})
+	})
+
+	// Rows are parsed by as many workers as the client allows requests, their
+	// images are downloaded as they go
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		elements = []Element{}
+	)
+	jobs := make(chan *goquery.Selection)
+	for range r.client.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				element := r.parseElement(row)
+				if element != nil {
+					mu.Lock()
+					elements = append(elements, *element)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, row := range rows {
+		jobs <- row
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Workers finish in any order, sort so runs on the same page give the same file
+	sort.SliceStable(elements, func(i, j int) bool {
+		return elements[i].Name < elements[j].Name
+	})
+
+	return elements
+}
+
+func (r *run) getMissingElementsIngredients(elements []Element, doc *goquery.Document) []Element {
+	// 1. Collect all existing element names
+	existing := make(map[string]bool)
+	for _, e := range elements {
+		existing[e.Name] = true
+	}
+
+	// 2. Collect all ingredient names from recipes
+	used := make(map[string]bool)
+	for _, e := range elements {
+		for _, recipe := range e.Recipes {
+			for _, ing := range recipe {
+				used[ing] = true
+			}
+		}
+	}
+
+	// 3. Find missing ones
+	var missing []string
+	for ing := range used {
+		if !existing[ing] {
+			missing = append(missing, ing)
+		}
+	}
+	sort.Strings(missing)
+	r.report.MissingIngredients = append(r.report.MissingIngredients, missing...)
+	log.Printf("Found %d missing ingredients. Attempting to scrape them...", len(missing))
+
+	// 4. Try to scrape each missing element
+	for _, name := range missing {
+		row := findRowByElementName(doc, name)
+		if row != nil {
+			if el := r.parseElement(row); el != nil {
+				elements = append(elements, *el)
+				r.report.RecoveredIngredients = append(r.report.RecoveredIngredients, name)
+			}
+		} else {
+			log.Printf("Could not find row for missing ingredient: %s", name)
+			imagePath := r.downloadImageFromIngredient(doc, name)
+			elements = append(elements, Element{Name: name, Recipes: [][]string{}, ImagePath: imagePath})
+			r.report.PlaceholderElements = append(r.report.PlaceholderElements, name)
+		}
+	}
+
+	return elements
+}
+
+func findRowByElementName(doc *goquery.Document, name string) *goquery.Selection {
+	var result *goquery.Selection
+	doc.Find("table.list-table.col-list.icon-hover").Each(func(_ int, table *goquery.Selection) {
+		table.Find("tr").EachWithBreak(func(i int, row *goquery.Selection) bool {
+			text := row.Find("td").First().Text()
+			if strings.EqualFold(strings.TrimSpace(text), name) {
+				result = row
+				return false
+			}
+			return true
+		})
+	})
+	return result
+}
+
+// Image download outcomes counted in the report
+const (
+	imageDownloaded = iota
+	imageSkipped
+	imageFailed
+)
+
+func (r *run) countImage(outcome int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch outcome {
+	case imageDownloaded:
+		r.report.Images.Downloaded++
+	case imageSkipped:
+		r.report.Images.Skipped++
+	case imageFailed:
+		r.report.Images.Failed++
+	}
+}
+
+func (r *run) addParseProblem(element string, entry string, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.ParseProblems = append(r.report.ParseProblems, ParseProblem{Element: element, Entry: entry, Reason: reason})
+}
@@ -0,0 +1,117 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// SpriteAtlas is written next to the sprite sheets and read by the backend
+// (models/sprites.go). Sprites are keyed by the file name of the icon.
+type SpriteAtlas struct {
+	CellSize int                  `json:"cell_size"`
+	Sheets   []SpriteSheet        `json:"sheets"`
+	Sprites  map[string]SpriteRef `json:"sprites"`
+}
+
+type SpriteSheet struct {
+	ID     int    `json:"id"`
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type SpriteRef struct {
+	Sheet int `json:"sheet"`
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	W     int `json:"w"`
+	H     int `json:"h"`
+}
+
+// BuildSprites packs every icon in dir into sprite sheets of at most
+// perSheet icons, written with atlas.json to dir/sprites.
+func BuildSprites(dir string, cellSize int, columns int, perSheet int) error {
+	if cellSize < 1 || columns < 1 || perSheet < 1 {
+		return fmt.Errorf("cell, columns and per-sheet must be positive")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	outDir := filepath.Join(dir, "sprites")
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	atlas := SpriteAtlas{CellSize: cellSize, Sheets: []SpriteSheet{}, Sprites: map[string]SpriteRef{}}
+	for start := 0; start < len(names); start += perSheet {
+		batch := names[start:min(start+perSheet, len(names))]
+		sheetID := len(atlas.Sheets)
+		rows := (len(batch) + columns - 1) / columns
+		sheet := image.NewNRGBA(image.Rect(0, 0, min(len(batch), columns)*cellSize, rows*cellSize))
+
+		for i, name := range batch {
+			img, err := readPNG(filepath.Join(dir, name))
+			if err != nil {
+				log.Printf("Skipping %s: %v", name, err)
+				continue
+			}
+			cell := image.Rect(0, 0, cellSize, cellSize).Add(image.Pt(i%columns*cellSize, i/columns*cellSize))
+			draw.CatmullRom.Scale(sheet, cell, img, img.Bounds(), draw.Over, nil)
+			atlas.Sprites[name] = SpriteRef{Sheet: sheetID, X: cell.Min.X, Y: cell.Min.Y, W: cellSize, H: cellSize}
+		}
+
+		fileName := fmt.Sprintf("sheet-%d.png", sheetID)
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, sheet); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(outDir, fileName), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		atlas.Sheets = append(atlas.Sheets, SpriteSheet{
+			ID:     sheetID,
+			Path:   "sprites/" + fileName,
+			Width:  sheet.Bounds().Dx(),
+			Height: sheet.Bounds().Dy(),
+		})
+	}
+
+	data, err := json.MarshalIndent(atlas, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "atlas.json"), data, 0644); err != nil {
+		return err
+	}
+	log.Printf("Packed %d icons into %d sprite sheets in %s", len(atlas.Sprites), len(atlas.Sheets), outDir)
+	return nil
+}
+
+func readPNG(filePath string) (image.Image, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(data))
+}
@@ -2,4 +2,4 @@
 
 # Run scraper first, then backend
 ./scraper
-./backend
+exec ./backend